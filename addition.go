@@ -0,0 +1,76 @@
+package main
+
+// BestAddition is the order a planner should try to add next: the best
+// paying one left off the load that no rule keeps out, and how much room
+// it needs. An order in an order group comes with the rest of its group,
+// and the amounts cover all of them.
+type BestAddition struct {
+	OrderID string `json:"order_id" xml:"order_id"`
+	// Every order that goes on with it, itself included, in request order
+	OrderIDs    []string `json:"order_ids" xml:"order_ids>id"`
+	PayoutCents int64    `json:"payout_cents" xml:"payout_cents"`
+	// Whether it fits as the load stands; otherwise the weight and volume
+	// to take off first, and the weight to take off each axle group
+	Fits               bool       `json:"fits" xml:"fits"`
+	MakeRoomWeightLbs  Quantity   `json:"make_room_weight_lbs" xml:"make_room_weight_lbs"`
+	MakeRoomVolumeCuft Quantity   `json:"make_room_volume_cuft" xml:"make_room_volume_cuft"`
+	MakeRoomAxleLbs    []Quantity `json:"make_room_axle_lbs,omitempty" xml:"make_room_axle_lbs>axle"`
+}
+
+// bestAddition picks, among the orders missing from the response, the
+// highest payout one whose addition breaks no compatibility rule, one that
+// fits winning ties, then request order. Capacity and axle limits are the
+// only violations allowed, since making room is something a planner can
+// do. An order joins with its order groups, like in rejectedOrders, and is
+// judged by what the whole group pays and needs. It works on the request
+// as the client sent it, so amounts are in the client's units, and returns
+// nil when no missing order is compatible with the load.
+func bestAddition(resp *OptimizeResponse, req *OptimizeRequest) *BestAddition {
+	o := baseOptimizer(req)
+	mask := 0
+	for _, i := range resp.selectedIndices {
+		mask |= 1 << i
+	}
+	// Axle limits are checked below as room to make, not as a rule
+	axles := o.axleMasks
+	o.axleMasks = nil
+	payout, _, _ := o.maskTotals(mask)
+
+	var best *BestAddition
+	for i, order := range req.Orders {
+		bit := 1 << i
+		if mask&bit != 0 {
+			continue
+		}
+		candidate := mask | bit
+		for _, g := range o.groups {
+			if g&bit != 0 {
+				candidate |= g
+			}
+		}
+		if !o.isValidSubset(candidate) {
+			continue
+		}
+		candidatePayout, weight, volume := o.maskTotals(candidate)
+		next := &BestAddition{
+			OrderID:            order.ID,
+			OrderIDs:           maskIDs(req, candidate&^mask),
+			PayoutCents:        candidatePayout - payout,
+			MakeRoomWeightLbs:  max(0, weight-o.weightCap),
+			MakeRoomVolumeCuft: max(0, volume-o.volumeCap),
+		}
+		next.Fits = next.MakeRoomWeightLbs == 0 && next.MakeRoomVolumeCuft == 0
+		if axles != nil {
+			next.MakeRoomAxleLbs = make([]Quantity, len(axles))
+			for axle, orders := range axles {
+				_, axleWeight, _ := o.maskTotals(candidate & orders)
+				next.MakeRoomAxleLbs[axle] = max(0, axleWeight-req.Truck.AxleCapacities[axle])
+				next.Fits = next.Fits && next.MakeRoomAxleLbs[axle] == 0
+			}
+		}
+		if best == nil || next.PayoutCents > best.PayoutCents || next.PayoutCents == best.PayoutCents && next.Fits && !best.Fits {
+			best = next
+		}
+	}
+	return best
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// requireAdmin guards operator endpoints with ADMIN_API_KEY, passed either as
+// "Authorization: Bearer <key>" or "X-API-Key: <key>". When no key is
+// configured the endpoints are disabled rather than left open.
+func requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if checkAdmin(w, r) {
+			next(w, r)
+		}
+	}
+}
+
+// checkAdmin reports whether r carries ADMIN_API_KEY, the way requireAdmin
+// checks it. Otherwise it writes the error response and returns false.
+func checkAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if cfg.AdminAPIKey == "" {
+		writeError(w, r, http.StatusForbidden, errCodeForbidden, "admin access is disabled (ADMIN_API_KEY not set)")
+		return false
+	}
+
+	key := r.Header.Get("X-API-Key")
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		key = strings.TrimPrefix(auth, "Bearer ")
+	}
+	if subtle.ConstantTimeCompare([]byte(key), []byte(cfg.AdminAPIKey)) != 1 {
+		writeError(w, r, http.StatusUnauthorized, errCodeUnauthorized, "invalid or missing API key")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,71 @@
+package main
+
+// maxAxles bounds truck.axle_capacities
+const maxAxles = 10
+
+// AxleLoad is the selected orders' weight on one axle group
+type AxleLoad struct {
+	Axle               int      `json:"axle" xml:"axle"`
+	WeightLbs          Quantity `json:"weight_lbs" xml:"weight_lbs"`
+	CapacityLbs        Quantity `json:"capacity_lbs" xml:"capacity_lbs"`
+	UtilizationPercent float64  `json:"utilization_percent" xml:"utilization_percent"`
+}
+
+// axleMasks returns, per axle group, a bitmask of the orders resting on it,
+// or nil when the truck has no axle limits
+func axleMasks(orders []Order, axles int) []int {
+	if axles == 0 {
+		return nil
+	}
+	masks := make([]int, axles)
+	for i, order := range orders {
+		masks[order.AxlePosition] |= 1 << i
+	}
+	return masks
+}
+
+// axleFits reports whether adding order i to the valid subset prev keeps
+// its axle within capacity. Only that axle gains weight, and its share of
+// prev is itself a valid subset, so the DP tables already hold its weight.
+func (o *Optimizer) axleFits(prev, i int) bool {
+	axle := o.orders[i].AxlePosition
+	return o.weight[prev&o.axleMasks[axle]]+o.orders[i].WeightLbs <= o.truck.AxleCapacities[axle]
+}
+
+// axlesWithin reports whether every axle group carries at most its capacity,
+// without the DP tables
+func (o *Optimizer) axlesWithin(mask int) bool {
+	for axle, orders := range o.axleMasks {
+		if _, weight, _ := o.maskTotals(mask & orders); weight > o.truck.AxleCapacities[axle] {
+			return false
+		}
+	}
+	return true
+}
+
+// overAxle reports whether order i alone is heavier than its axle allows
+func (o *Optimizer) overAxle(i int) bool {
+	return o.axleMasks != nil && o.orders[i].WeightLbs > o.truck.AxleCapacities[o.orders[i].AxlePosition]
+}
+
+// axleLoads reports each axle group's share of the selected orders in the
+// request's own units, like fromInternalUnits does for the totals. It is
+// nil when the truck has no axle limits.
+func axleLoads(resp *OptimizeResponse, req *OptimizeRequest) []AxleLoad {
+	if len(req.Truck.AxleCapacities) == 0 {
+		return nil
+	}
+	loads := make([]AxleLoad, len(req.Truck.AxleCapacities))
+	for axle, capacity := range req.Truck.AxleCapacities {
+		loads[axle] = AxleLoad{Axle: axle, CapacityLbs: capacity}
+	}
+	for _, i := range resp.selectedIndices {
+		order := req.Orders[i]
+		loads[order.AxlePosition].WeightLbs += order.WeightLbs
+	}
+	for axle := range loads {
+		_, pct := capacityUsage(loads[axle].WeightLbs, loads[axle].CapacityLbs, false)
+		loads[axle].UtilizationPercent = roundPercent(pct)
+	}
+	return loads
+}
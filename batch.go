@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const contentTypeNDJSON = "application/x-ndjson"
+
+// BatchRequest solves several independent optimize requests in one call
+type BatchRequest struct {
+	Requests []OptimizeRequest `json:"requests"`
+}
+
+// BatchItem holds the outcome for the request at Index: a result, or the
+// validation error that kept it from being solved
+type BatchItem struct {
+	Index  int               `json:"index"`
+	Result *OptimizeResponse `json:"result,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchItem   `json:"results"`
+	Summary *BatchSummary `json:"summary,omitempty"`
+}
+
+// BatchSummary is an at-a-glance fleet report over a batch's solved items.
+// Each truck is bucketed by the higher of its weight and volume utilization.
+type BatchSummary struct {
+	Solved           int   `json:"solved"`
+	Failed           int   `json:"failed"`
+	TotalPayoutCents int64 `json:"total_payout_cents"`
+	Utilization      struct {
+		Under50    int `json:"under_50"`
+		From50To90 int `json:"50_to_90"`
+		Over90     int `json:"90_plus"`
+	} `json:"utilization"`
+}
+
+// summarizeBatch builds the fleet report from the individual results
+func summarizeBatch(items []BatchItem) *BatchSummary {
+	var s BatchSummary
+	for _, item := range items {
+		if item.Result == nil {
+			s.Failed++
+			continue
+		}
+		s.Solved++
+		s.TotalPayoutCents += item.Result.TotalPayoutCents
+		switch u := max(item.Result.UtilizationWeightPercent, item.Result.UtilizationVolumePercent); {
+		case u >= 90:
+			s.Utilization.Over90++
+		case u >= 50:
+			s.Utilization.From50To90++
+		default:
+			s.Utilization.Under50++
+		}
+	}
+	return &s
+}
+
+// batchSem bounds concurrent batch solves across all requests so a large
+// batch can't pin every core
+var batchSem = make(chan struct{}, cfg.BatchWorkers)
+
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req BatchRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	// The whole batch counts as one solve against MAX_CONCURRENT_SOLVES;
+	// batchSem still bounds how many of its items run at once
+	if !acquireSolve(w, r) {
+		return
+	}
+	defer globalSolves.release()
+
+	if strings.Contains(r.Header.Get("Accept"), contentTypeNDJSON) {
+		streamBatch(w, r, &req)
+		return
+	}
+
+	results := make([]BatchItem, len(req.Requests))
+	var wg sync.WaitGroup
+	for i := range req.Requests {
+		results[i].Index = i
+		item := &req.Requests[i]
+		if err := validateRequest(item); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+
+		wg.Add(1)
+		batchSem <- struct{}{}
+		go func(i int) {
+			defer func() { <-batchSem; wg.Done() }()
+			result, _, _, err := solveCached(r.Context(), item, cfg.SolverDeadline)
+			if err != nil {
+				results[i].Error = err.Error()
+				return
+			}
+			results[i].Result = result
+		}(i)
+	}
+	wg.Wait()
+
+	resp := BatchResponse{Results: results}
+	if r.URL.Query().Get("summary") == "true" {
+		resp.Summary = summarizeBatch(results)
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// streamBatch writes one BatchItem per line as each solve finishes, flushing
+// after every line so clients can start on early results. Items arrive in
+// completion order unless ?ordered=true, which holds each one back until all
+// earlier items have been written.
+func streamBatch(w http.ResponseWriter, r *http.Request, req *BatchRequest) {
+	items := make(chan BatchItem, len(req.Requests))
+	go func() {
+		var wg sync.WaitGroup
+		for i := range req.Requests {
+			item := &req.Requests[i]
+			if err := validateRequest(item); err != nil {
+				items <- BatchItem{Index: i, Error: err.Error()}
+				continue
+			}
+
+			wg.Add(1)
+			batchSem <- struct{}{}
+			go func(i int) {
+				defer func() { <-batchSem; wg.Done() }()
+				result, _, _, err := solveCached(r.Context(), item, cfg.SolverDeadline)
+				if err != nil {
+					items <- BatchItem{Index: i, Error: err.Error()}
+					return
+				}
+				items <- BatchItem{Index: i, Result: result}
+			}(i)
+		}
+		wg.Wait()
+		close(items)
+	}()
+
+	w.Header().Set("Content-Type", contentTypeNDJSON)
+	w.WriteHeader(http.StatusOK)
+	enc := json.NewEncoder(w)
+	rc := http.NewResponseController(w)
+
+	ordered := r.URL.Query().Get("ordered") == "true"
+	pending := make(map[int]BatchItem)
+	next := 0
+	for item := range items {
+		if !ordered {
+			enc.Encode(item)
+			rc.Flush()
+			continue
+		}
+		pending[item.Index] = item
+		for {
+			ready, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			enc.Encode(ready)
+			next++
+		}
+		rc.Flush()
+	}
+}
+
+// ValidationItem is the validation outcome for the batch request at Index
+type ValidationItem struct {
+	Index int    `json:"index"`
+	Valid bool   `json:"valid"`
+	Error string `json:"error,omitempty"`
+}
+
+type BatchValidateResponse struct {
+	Results []ValidationItem `json:"results"`
+}
+
+// batchValidateHandler runs the batch checks on every item without solving
+// any, so bad data in a large batch can be fixed before the expensive call
+func batchValidateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req BatchRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	resp := BatchValidateResponse{Results: make([]ValidationItem, len(req.Requests))}
+	for i := range req.Requests {
+		resp.Results[i] = ValidationItem{Index: i, Valid: true}
+		if err := validateRequest(&req.Requests[i]); err != nil {
+			resp.Results[i] = ValidationItem{Index: i, Error: err.Error()}
+		}
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// WarmResponse reports how many requests from a warm call are now cached
+type WarmResponse struct {
+	Warmed  int `json:"warmed"`
+	Invalid int `json:"invalid"`
+}
+
+// warmHandler solves requests ahead of time so later identical requests hit
+// the cache. It shares the batch worker pool and returns only counts.
+func warmHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req BatchRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	// Counted like a batch
+	if !acquireSolve(w, r) {
+		return
+	}
+	defer globalSolves.release()
+
+	var mu sync.Mutex
+	var resp WarmResponse
+	var wg sync.WaitGroup
+	for i := range req.Requests {
+		item := &req.Requests[i]
+		if err := validateRequest(item); err != nil {
+			resp.Invalid++
+			continue
+		}
+
+		wg.Add(1)
+		batchSem <- struct{}{}
+		go func() {
+			defer func() { <-batchSem; wg.Done() }()
+			// Heuristic and empty results may not be cached, so count what
+			// actually landed in the cache
+			_, key, _, _ := solveCached(r.Context(), item, cfg.SolverDeadline)
+			if _, found := globalCache.get(key); key != "" && found {
+				mu.Lock()
+				resp.Warmed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
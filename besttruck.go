@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// BestTruckRequest asks which single truck earns the most for a set of orders
+type BestTruckRequest struct {
+	Trucks     []Truck `json:"trucks"`
+	Orders     []Order `json:"orders"`
+	WeightUnit string  `json:"weight_unit,omitempty"`
+	VolumeUnit string  `json:"volume_unit,omitempty"`
+}
+
+type BestTruckResponse struct {
+	BestTruckID string            `json:"best_truck_id"`
+	Result      *OptimizeResponse `json:"result"`
+}
+
+// requests expands the fleet request into one OptimizeRequest per truck
+func (b *BestTruckRequest) requests() []*OptimizeRequest {
+	reqs := make([]*OptimizeRequest, len(b.Trucks))
+	for i, t := range b.Trucks {
+		reqs[i] = &OptimizeRequest{Truck: t, Orders: b.Orders, WeightUnit: b.WeightUnit, VolumeUnit: b.VolumeUnit}
+	}
+	return reqs
+}
+
+func bestTruckHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req BestTruckRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if len(req.Trucks) == 0 {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, "trucks must not be empty")
+		return
+	}
+
+	reqs := req.requests()
+	for i, tr := range reqs {
+		if err := validateRequest(tr); err != nil {
+			writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, fmt.Sprintf("trucks[%d]: %s", i, err.Error()))
+			return
+		}
+	}
+
+	if !acquireSolve(w, r) {
+		return
+	}
+	defer globalSolves.release()
+
+	// Solve per truck; on equal payout the earlier truck wins
+	var best *OptimizeResponse
+	for _, tr := range reqs {
+		resp, err := solve(r.Context(), tr, cfg.SolverDeadline)
+		if err != nil {
+			writeSolveError(w, r, err)
+			return
+		}
+		if best == nil || resp.TotalPayoutCents > best.TotalPayoutCents {
+			best = resp
+		}
+	}
+
+	writeJSON(w, r, http.StatusOK, BestTruckResponse{BestTruckID: best.TruckID, Result: best})
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// FieldCapability describes one request field for client generators. Name is
+// a path: nested objects use dots and list items use [], e.g.
+// orders[].weight_lbs.
+type FieldCapability struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Default  any      `json:"default,omitempty"`
+	Enum     []string `json:"enum,omitempty"`
+	Minimum  *float64 `json:"minimum,omitempty"`
+	Maximum  *float64 `json:"maximum,omitempty"`
+	// Set instead of Minimum when the bound itself is rejected
+	ExclusiveMinimum *float64 `json:"exclusive_minimum,omitempty"`
+	// Most characters in a string field
+	MaxLength int `json:"max_length,omitempty"`
+	// Fractional digits a number field accepts
+	Decimals int `json:"decimals,omitempty"`
+	// Another field this one only applies with, or only counts without
+	DependsOn string `json:"depends_on,omitempty"`
+}
+
+// CapabilitiesResponse lists everything an /optimize request can carry
+type CapabilitiesResponse struct {
+	Fields []FieldCapability `json:"fields"`
+	Limits map[string]int64  `json:"limits"`
+}
+
+// fieldRule is what validateRequest enforces on a field beyond its type.
+// Enums use the same constants the validation switches on, but a new value
+// or bound there must still be added here; TestFieldRulesMatchValidation
+// catches rules that disagree with validateRequest.
+type fieldRule struct {
+	required bool
+	def      any
+	enum     []string
+	min, max *float64
+	// Lower bound the value must be above
+	exclusiveMin *float64
+	maxLength    int
+	dependsOn    string
+}
+
+func bound(v float64) *float64 { return &v }
+
+// Smallest positive quantity, one hundredth of a unit
+var minQuantity = bound(1.0 / quantityScale)
+
+// fieldRules returns the rule for each field by capability name. It is
+// built on each call since some rules follow the live config.
+func fieldRules() map[string]fieldRule {
+	return map[string]fieldRule{
+		"truck":                              {required: true},
+		"truck.id":                           {required: true},
+		"truck.max_weight_lbs":               {required: true, min: minQuantity, dependsOn: "ignore_weight"},
+		"truck.max_volume_cuft":              {required: true, min: minQuantity, dependsOn: "ignore_volume"},
+		"truck.transit_days":                 {def: 0, min: bound(0)},
+		"truck.weight_overage_percent":       {def: 0, min: bound(0), max: bound(100)},
+		"truck.overage_penalty_cents_per_lb": {def: 0, min: bound(0)},
+		"truck.hazmat_allowed":               {def: true},
+		"truck.co2_grams_per_mile":           {def: 0, min: bound(0)},
+		"truck.fixed_cost_cents":             {def: 0, min: bound(0)},
+		"truck.preloaded_weight_lbs":         {def: 0, min: bound(0)},
+		"truck.preloaded_volume_cuft":        {def: 0, min: bound(0)},
+		"truck.axle_capacities":              {min: minQuantity},
+		"orders":                             {def: []Order{}},
+		"orders[].id":                        {required: true},
+		"orders[].payout_cents":              {def: 0, min: bound(0)},
+		"orders[].weight_lbs":                {def: 0, min: bound(0)},
+		"orders[].volume_cuft":               {def: 0, min: bound(0)},
+		"orders[].origin":                    {required: true},
+		"orders[].destination":               {required: true},
+		"orders[].pickup_date":               {required: true},
+		"orders[].delivery_date":             {required: true},
+		"orders[].is_hazmat":                 {def: false},
+		"orders[].distance_miles":            {def: 0, min: bound(0)},
+		"orders[].cost_cents":                {def: 0, min: bound(0)},
+		"orders[].category":                  {dependsOn: "max_category_percent"},
+		"orders[].currency":                  {required: cfg.StrictCurrency, def: cfg.DefaultCurrency, maxLength: 3},
+		"orders[].axle_position":             {def: 0, min: bound(0), max: bound(maxAxles - 1), dependsOn: "truck.axle_capacities"},
+		"allow_multi_origin":                 {def: false},
+		"max_origins":                        {def: 0, min: bound(0), dependsOn: "allow_multi_origin"},
+		"allow_multi_destination":            {def: false},
+		"max_destinations":                   {def: 0, min: bound(0), dependsOn: "allow_multi_destination"},
+		"max_orders_per_destination":         {def: 0, min: bound(0)},
+		"co_load_bonuses[].order_a":          {required: true},
+		"co_load_bonuses[].order_b":          {required: true},
+		"co_load_bonuses[].bonus_cents":      {min: bound(0)},
+		"ignore_weight":                      {def: false},
+		"ignore_volume":                      {def: false},
+		"preference_bonus_cents":             {def: 0, min: bound(0)},
+		"min_total_payout_cents":             {def: 0, min: bound(0)},
+		"tie_break_by_delivery":              {def: false},
+		"max_category_percent":               {def: 0, min: bound(0), max: bound(100)},
+		"greedy_sort":                        {enum: []string{greedySortPayoutPerWeight, greedySortPayoutPerVolume, greedySortPayout}},
+		"solver":                             {enum: []string{solverExact, solverGreedy, solverMeetInMiddle}},
+		"objective":                          {enum: []string{objectiveMaximinMargin, objectiveTargetUtilization}},
+		"target_weight_percent":              {exclusiveMin: bound(0), max: bound(100), dependsOn: "objective"},
+		"pricing_snapshot_id":                {maxLength: 128},
+		"weight_unit":                        {def: unitLbs, enum: []string{unitLbs, unitKg}},
+		"volume_unit":                        {def: unitCuft, enum: []string{unitCuft, unitM3}},
+	}
+}
+
+// requestCapabilities walks OptimizeRequest itself, so a new field shows up
+// with its real JSON name and type even before it gets a rule
+func requestCapabilities() []FieldCapability {
+	rules := fieldRules()
+	var fields []FieldCapability
+	var walk func(prefix string, t reflect.Type)
+	walk = func(prefix string, t reflect.Type) {
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			name = prefix + name
+			rule := rules[name]
+			field := FieldCapability{
+				Name:             name,
+				Type:             capabilityType(f.Type),
+				Required:         rule.required,
+				Default:          rule.def,
+				Enum:             rule.enum,
+				Minimum:          rule.min,
+				Maximum:          rule.max,
+				ExclusiveMinimum: rule.exclusiveMin,
+				MaxLength:        rule.maxLength,
+				DependsOn:        rule.dependsOn,
+			}
+			if f.Type == reflect.TypeFor[Quantity]() {
+				field.Decimals = quantityDecimals
+			}
+			fields = append(fields, field)
+
+			if f.Type.Kind() == reflect.Struct {
+				walk(name+".", f.Type)
+			} else if f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() == reflect.Struct {
+				walk(name+"[].", f.Type.Elem())
+			}
+		}
+	}
+	walk("", reflect.TypeFor[OptimizeRequest]())
+	return fields
+}
+
+// capabilityType names a Go type the way JSON clients see it
+func capabilityType(t reflect.Type) string {
+	if t == reflect.TypeFor[Quantity]() {
+		return "number"
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return capabilityType(t.Elem())
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int64:
+		return "integer"
+	case reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "array<" + capabilityType(t.Elem()) + ">"
+	default:
+		return "object"
+	}
+}
+
+func capabilitiesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, CapabilitiesResponse{
+		Fields: requestCapabilities(),
+		Limits: map[string]int64{
+			"max_orders":             int64(cfg.orderCap()),
+			"max_relative_date_days": maxRelativeDays,
+			"exact_max_orders":       int64(cfg.ExactMaxOrders),
+			"mim_max_orders":         int64(cfg.MimMaxOrders),
+			"max_subsets":            cfg.MaxSubsets,
+			"max_request_body_bytes": cfg.MaxBodyBytes,
+		},
+	})
+}
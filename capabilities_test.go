@@ -0,0 +1,139 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ruleSetups switch on what a field depends on before it is checked
+var ruleSetups = map[string]func(req *OptimizeRequest){
+	"co_load_bonuses[].order_a":     withCoLoadBonus,
+	"co_load_bonuses[].order_b":     withCoLoadBonus,
+	"co_load_bonuses[].bonus_cents": withCoLoadBonus,
+	"max_origins":                   func(req *OptimizeRequest) { req.AllowMultiOrigin = true },
+	"max_destinations":              func(req *OptimizeRequest) { req.AllowMultiDestination = true },
+	"target_weight_percent":         func(req *OptimizeRequest) { req.Objective = objectiveTargetUtilization },
+	"orders[].axle_position": func(req *OptimizeRequest) {
+		for range maxAxles {
+			req.Truck.AxleCapacities = append(req.Truck.AxleCapacities, wholeQuantity(44000))
+		}
+	},
+}
+
+func withCoLoadBonus(req *OptimizeRequest) {
+	req.Orders = append(req.Orders, testOrder("b", 1000, 1000, 100))
+	req.CoLoadBonuses = []CoLoadBonus{{OrderA: "a", OrderB: "b", BonusCents: 100}}
+}
+
+// ruleRequest returns a valid request with the field at path, its
+// dependencies switched on, and set points at the field
+func ruleRequest(t *testing.T, path string) (*OptimizeRequest, reflect.Value) {
+	t.Helper()
+	req := testRequest(testOrder("a", 2000, 1000, 100))
+	if setup := ruleSetups[path]; setup != nil {
+		setup(req)
+	}
+	v := reflect.ValueOf(req).Elem()
+	for _, part := range strings.Split(path, ".") {
+		name, list := strings.CutSuffix(part, "[]")
+		v = jsonField(v, name)
+		if !v.IsValid() {
+			t.Fatalf("%s: no request field %s", path, name)
+		}
+		if list {
+			v = v.Index(0)
+		}
+	}
+	return req, v
+}
+
+// jsonField returns the field of struct v whose JSON name is name
+func jsonField(v reflect.Value, name string) reflect.Value {
+	for i := 0; i < v.NumField(); i++ {
+		if tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("json"), ","); tag == name {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// setNumber stores x in a numeric field, or in a one-item list of numbers,
+// and returns the smallest step the field can take
+func setNumber(v reflect.Value, x float64) float64 {
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), 1, 1))
+		v = v.Index(0)
+	}
+	switch {
+	case v.Type() == reflect.TypeFor[Quantity]():
+		v.SetInt(int64(math.Round(x * quantityScale)))
+		return 1.0 / quantityScale
+	case v.Kind() == reflect.Float64:
+		v.SetFloat(x)
+		return 0.001
+	default:
+		v.SetInt(int64(x))
+		return 1
+	}
+}
+
+// TestFieldRulesMatchValidation fails when /capabilities advertises a rule
+// validateRequest doesn't enforce, or enforces one it doesn't advertise
+func TestFieldRulesMatchValidation(t *testing.T) {
+	saved := cfg.StrictCurrency
+	defer func() { cfg.StrictCurrency = saved }()
+
+	// Rules follow the live config, so check them under both settings
+	for _, strict := range []bool{false, true} {
+		cfg.StrictCurrency = strict
+		for path, rule := range fieldRules() {
+			check := func(what string, want bool, set func(req *OptimizeRequest, v reflect.Value)) {
+				t.Helper()
+				req, v := ruleRequest(t, path)
+				for i := range req.Orders {
+					req.Orders[i].Currency = "USD"
+				}
+				set(req, v)
+				if err := validateRequest(req); (err == nil) != want {
+					t.Errorf("strict_currency=%t: %s %s: err %v, want valid %t", strict, path, what, err, want)
+				}
+			}
+
+			switch {
+			case rule.required:
+				check("unset", false, func(_ *OptimizeRequest, v reflect.Value) { v.Set(reflect.Zero(v.Type())) })
+			case rule.dependsOn == "":
+				check("unset", true, func(_ *OptimizeRequest, v reflect.Value) { v.Set(reflect.Zero(v.Type())) })
+			}
+			if rule.min != nil {
+				check("at minimum", true, func(_ *OptimizeRequest, v reflect.Value) { setNumber(v, *rule.min) })
+				check("below minimum", false, func(_ *OptimizeRequest, v reflect.Value) { setNumber(v, *rule.min-setNumber(v, 0)) })
+			}
+			if rule.exclusiveMin != nil {
+				check("at exclusive minimum", false, func(_ *OptimizeRequest, v reflect.Value) { setNumber(v, *rule.exclusiveMin) })
+				check("above exclusive minimum", true, func(_ *OptimizeRequest, v reflect.Value) { setNumber(v, *rule.exclusiveMin+setNumber(v, 0)) })
+			}
+			if rule.max != nil {
+				check("at maximum", true, func(_ *OptimizeRequest, v reflect.Value) { setNumber(v, *rule.max) })
+				check("above maximum", false, func(_ *OptimizeRequest, v reflect.Value) { setNumber(v, *rule.max+setNumber(v, 0)) })
+			}
+			if rule.maxLength > 0 {
+				check("at max length", true, func(_ *OptimizeRequest, v reflect.Value) { v.SetString(strings.Repeat("A", rule.maxLength)) })
+				check("over max length", false, func(_ *OptimizeRequest, v reflect.Value) { v.SetString(strings.Repeat("A", rule.maxLength+1)) })
+			}
+			for _, value := range rule.enum {
+				check(value, true, func(req *OptimizeRequest, v reflect.Value) {
+					v.SetString(value)
+					if value == objectiveTargetUtilization {
+						req.TargetWeightPercent = 50
+					}
+				})
+			}
+			if rule.enum != nil {
+				check("outside the enum", false, func(_ *OptimizeRequest, v reflect.Value) { v.SetString("bogus") })
+			}
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+)
+
+// maxCapacityScenarios bounds the solves one /simulate-capacity call may run
+const maxCapacityScenarios = 20
+
+// maxCapacityMultiplier keeps scaled capacities well inside int64
+const maxCapacityMultiplier = 10
+
+// capacityMultiplierScale is the precision multipliers are applied at, so
+// 0.57 scales by exactly 570000/1000000 rather than by the nearest float
+const capacityMultiplierScale = 1000000
+
+// Capacities a scenario may scale
+const (
+	capacityDimensionBoth   = "both"
+	capacityDimensionWeight = "weight"
+	capacityDimensionVolume = "volume"
+)
+
+// CapacityScenarioRequest is an optimize request plus the truck sizes to try
+// it on, each a multiple of the request's truck
+type CapacityScenarioRequest struct {
+	Request             OptimizeRequest `json:"request"`
+	CapacityMultipliers []float64       `json:"capacity_multipliers"`
+	// Which capacity the multipliers scale; "both" when omitted
+	Dimension string `json:"dimension,omitempty"`
+}
+
+// CapacityScenario is the optimal load on one scaled truck. The delta is
+// against the request's own truck.
+type CapacityScenario struct {
+	Multiplier         float64  `json:"multiplier"`
+	MaxWeightLbs       Quantity `json:"max_weight_lbs"`
+	MaxVolumeCuft      Quantity `json:"max_volume_cuft"`
+	TotalPayoutCents   int64    `json:"total_payout_cents"`
+	PayoutDeltaCents   int64    `json:"payout_delta_cents"`
+	SelectedOrderCount int      `json:"selected_order_count"`
+	Optimal            bool     `json:"optimal"`
+}
+
+// CapacityScenarioResponse is the payout curve, in the order the
+// multipliers were given
+type CapacityScenarioResponse struct {
+	BasePayoutCents int64              `json:"base_payout_cents"`
+	Scenarios       []CapacityScenario `json:"scenarios"`
+}
+
+// scaledCapacity returns a copy of req with its truck's capacities scaled by
+// m, rounded down like other capacities. The multiplier is taken to six
+// decimals and applied in integer math. Preloaded cargo stays as it is, so
+// a multiplier that shrinks the truck below its preload is an error.
+func scaledCapacity(req *OptimizeRequest, m float64, dimension string) (*OptimizeRequest, error) {
+	num := uint64(math.Round(m * capacityMultiplierScale))
+	scaled := func(q Quantity) (Quantity, error) {
+		out, ok := scale(q, num, capacityMultiplierScale, false)
+		if !ok {
+			return 0, fmt.Errorf("%v scales a capacity past %d", m, int64(math.MaxInt64))
+		}
+		return out, nil
+	}
+
+	out := *req
+	var err error
+	if dimension != capacityDimensionVolume {
+		if out.Truck.MaxWeightLbs, err = scaled(req.Truck.MaxWeightLbs); err != nil {
+			return nil, err
+		}
+		if !req.IgnoreWeight && out.Truck.MaxWeightLbs < req.Truck.PreloadedWeightLbs {
+			return nil, fmt.Errorf("%v shrinks max_weight_lbs below preloaded_weight_lbs", m)
+		}
+		// A bigger truck has bigger axles; the limits scale with the rating
+		out.Truck.AxleCapacities = make([]Quantity, len(req.Truck.AxleCapacities))
+		for i, capacity := range req.Truck.AxleCapacities {
+			if out.Truck.AxleCapacities[i], err = scaled(capacity); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if dimension != capacityDimensionWeight {
+		if out.Truck.MaxVolumeCuft, err = scaled(req.Truck.MaxVolumeCuft); err != nil {
+			return nil, err
+		}
+		if !req.IgnoreVolume && out.Truck.MaxVolumeCuft < req.Truck.PreloadedVolumeCuft {
+			return nil, fmt.Errorf("%v shrinks max_volume_cuft below preloaded_volume_cuft", m)
+		}
+	}
+	return &out, nil
+}
+
+// simulateCapacityHandler solves the request once on its own truck and once
+// per multiplier, to show how much more a bigger truck would earn
+func simulateCapacityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req CapacityScenarioRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	switch req.Dimension {
+	case "":
+		req.Dimension = capacityDimensionBoth
+	case capacityDimensionBoth, capacityDimensionWeight, capacityDimensionVolume:
+	default:
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, "dimension must be both, weight or volume")
+		return
+	}
+	if len(req.CapacityMultipliers) == 0 || len(req.CapacityMultipliers) > maxCapacityScenarios {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, fmt.Sprintf("capacity_multipliers must have 1 to %d entries", maxCapacityScenarios))
+		return
+	}
+	if err := validateRequest(&req.Request); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+		return
+	}
+	scenarios := make([]*OptimizeRequest, len(req.CapacityMultipliers))
+	for i, m := range req.CapacityMultipliers {
+		if m <= 0 || m > maxCapacityMultiplier {
+			writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, fmt.Sprintf("capacity_multipliers[%d] must be above 0 and at most %d", i, maxCapacityMultiplier))
+			return
+		}
+		scaled, err := scaledCapacity(&req.Request, m, req.Dimension)
+		if err != nil {
+			writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, fmt.Sprintf("capacity_multipliers[%d]: %s", i, err))
+			return
+		}
+		scenarios[i] = scaled
+		if err := validateRequest(scenarios[i]); err != nil {
+			writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, fmt.Sprintf("capacity_multipliers[%d]: %s", i, err))
+			return
+		}
+	}
+
+	if !acquireSolve(w, r) {
+		return
+	}
+	defer globalSolves.release()
+
+	base, _, _, err := solveCached(r.Context(), &req.Request, cfg.SolverDeadline)
+	if err != nil {
+		writeSolveError(w, r, err)
+		return
+	}
+	resp := CapacityScenarioResponse{BasePayoutCents: base.TotalPayoutCents, Scenarios: make([]CapacityScenario, 0, len(scenarios))}
+	for i, q := range scenarios {
+		result, _, _, err := solveCached(r.Context(), q, cfg.SolverDeadline)
+		if err != nil {
+			writeSolveError(w, r, err)
+			return
+		}
+		resp.Scenarios = append(resp.Scenarios, CapacityScenario{
+			Multiplier:         req.CapacityMultipliers[i],
+			MaxWeightLbs:       q.Truck.MaxWeightLbs,
+			MaxVolumeCuft:      q.Truck.MaxVolumeCuft,
+			TotalPayoutCents:   result.TotalPayoutCents,
+			PayoutDeltaCents:   result.TotalPayoutCents - base.TotalPayoutCents,
+			SelectedOrderCount: len(result.SelectedOrderIDs),
+			Optimal:            result.Optimal,
+		})
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
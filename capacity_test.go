@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScaledCapacity(t *testing.T) {
+	req := testRequest()
+	req.Truck.MaxWeightLbs = wholeQuantity(26000)
+	req.Truck.AxleCapacities = []Quantity{wholeQuantity(26000)}
+	req.Truck.PreloadedWeightLbs = wholeQuantity(13000)
+
+	tests := []struct {
+		name       string
+		m          float64
+		dimension  string
+		wantWeight Quantity
+		wantErr    string
+	}{
+		// As a float product this is 1481999.9999999998 hundredths
+		{"exact", 0.57, capacityDimensionBoth, 1482000, ""},
+		{"grows", 1.25, capacityDimensionWeight, wholeQuantity(32500), ""},
+		{"down to the preload", 0.5, capacityDimensionBoth, wholeQuantity(13000), ""},
+		{"below the preload", 0.49, capacityDimensionBoth, 0, "shrinks max_weight_lbs below preloaded_weight_lbs"},
+		{"volume only", 0.49, capacityDimensionVolume, wholeQuantity(26000), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := scaledCapacity(req, tt.m, tt.dimension)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("scaledCapacity: %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("scaledCapacity: %v", err)
+			}
+			if out.Truck.MaxWeightLbs != tt.wantWeight || out.Truck.AxleCapacities[0] != tt.wantWeight {
+				t.Errorf("max_weight_lbs %s, axle %s; want %s", out.Truck.MaxWeightLbs, out.Truck.AxleCapacities[0], tt.wantWeight)
+			}
+		})
+	}
+
+	huge := testRequest()
+	huge.Truck.MaxWeightLbs = math.MaxInt64 / 2
+	if _, err := scaledCapacity(huge, 3, capacityDimensionBoth); err == nil {
+		t.Error("scaledCapacity past MaxInt64: no error")
+	}
+}
+
+func TestSimulateCapacityBelowPreload(t *testing.T) {
+	req := testRequest(testOrder("a", 1000, 1000, 100))
+	req.Truck.PreloadedWeightLbs = wholeQuantity(30000)
+	body, err := json.Marshal(CapacityScenarioRequest{Request: *req, CapacityMultipliers: []float64{1.5, 0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/simulate-capacity", bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentTypeJSON)
+	w := httptest.NewRecorder()
+	simulateCapacityHandler(w, r)
+	if want := "capacity_multipliers[1]: 0.5 shrinks max_weight_lbs below preloaded_weight_lbs"; w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), want) {
+		t.Errorf("status %d, body %s; want 422 %q", w.Code, w.Body, want)
+	}
+}
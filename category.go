@@ -0,0 +1,74 @@
+package main
+
+// categoryMasks returns, per distinct non-empty orders[].category, a bitmask
+// of the orders in it, or nil when the request has no category limit.
+// Categories match case-insensitively, like places.
+func categoryMasks(orders []Order, maxPercent float64) []int {
+	if maxPercent == 0 {
+		return nil
+	}
+	masks := []int{}
+	ids := placeIDs(orders, func(order Order) string { return order.Category })
+	for i, order := range orders {
+		if order.Category == "" {
+			continue
+		}
+		for int(ids[i]) >= len(masks) {
+			masks = append(masks, 0)
+		}
+		masks[ids[i]] |= 1 << i
+	}
+	return masks
+}
+
+// complete reports whether a load that passes the DP's checks may also be
+// dispatched as a whole. Unlike the DP's checks these can fail for a subset
+// of a good load, so they are only applied to finished loads.
+func (o *Optimizer) complete(mask int) bool {
+	return o.groupsComplete(mask) && o.overweightCategory(mask) == 0
+}
+
+// overweightCategory returns the orders of a category holding more than
+// max_category_percent of the load's weight, or 0 when every category is
+// within it. A weightless load has no share to exceed.
+func (o *Optimizer) overweightCategory(mask int) int {
+	if o.categoryMasks == nil || mask == 0 {
+		return 0
+	}
+	total := o.maskWeight(mask)
+	for _, category := range o.categoryMasks {
+		if float64(o.maskWeight(mask&category))*100 > o.maxCategoryPercent*float64(total) {
+			return category
+		}
+	}
+	return 0
+}
+
+// maskWeight reads a valid mask's weight from the DP tables, which
+// precompute builds incrementally and which also hold every subset of a
+// valid mask. Without the tables it sums the orders.
+func (o *Optimizer) maskWeight(mask int) Quantity {
+	if o.valid != nil && o.valid[mask] {
+		return o.weight[mask]
+	}
+	_, weight, _ := o.maskTotals(mask)
+	return weight
+}
+
+// balanceCategories drops orders from a greedy load until no category is
+// over max_category_percent, taking the last added unit of the category
+// that is over first. added lists the load's units in the order greedy
+// took them. Dropping units keeps the load within capacity and the DP's
+// checks, and the empty load is always balanced.
+func (o *Optimizer) balanceCategories(mask int, added []int) int {
+	for category := o.overweightCategory(mask); category != 0; category = o.overweightCategory(mask) {
+		for i := len(added) - 1; i >= 0; i-- {
+			if added[i]&category != 0 {
+				mask &^= added[i]
+				added = append(added[:i], added[i+1:]...)
+				break
+			}
+		}
+	}
+	return mask
+}
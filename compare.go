@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// CompareRequest asks how two candidate loads for the same truck differ
+type CompareRequest struct {
+	Truck      Truck    `json:"truck"`
+	Orders     []Order  `json:"orders"`
+	PlanA      []string `json:"plan_a"`
+	PlanB      []string `json:"plan_b"`
+	WeightUnit string   `json:"weight_unit,omitempty"`
+	VolumeUnit string   `json:"volume_unit,omitempty"`
+}
+
+// PlanResult is one side of a comparison. Feasible is false when the plan
+// exceeds capacity or mixes incompatible orders.
+type PlanResult struct {
+	Feasible bool              `json:"feasible"`
+	Result   *OptimizeResponse `json:"result"`
+}
+
+// CompareResponse reports both plans and the change going from A to B
+type CompareResponse struct {
+	PlanA                         PlanResult `json:"plan_a"`
+	PlanB                         PlanResult `json:"plan_b"`
+	PayoutDeltaCents              int64      `json:"payout_delta_cents"`
+	UtilizationWeightDeltaPercent float64    `json:"utilization_weight_delta_percent"`
+	UtilizationVolumeDeltaPercent float64    `json:"utilization_volume_delta_percent"`
+	OnlyInA                       []string   `json:"only_in_a"`
+	OnlyInB                       []string   `json:"only_in_b"`
+}
+
+// validatePlan checks that a plan names each known order at most once
+func validatePlan(name string, plan []string, orders []Order) error {
+	ids := make(map[string]bool, len(orders))
+	for _, o := range orders {
+		ids[o.ID] = true
+	}
+	seen := make(map[string]bool, len(plan))
+	for i, id := range plan {
+		if !ids[id] {
+			return fmt.Errorf("%s[%d] references unknown order id %q", name, i, id)
+		}
+		if seen[id] {
+			return fmt.Errorf("%s[%d] repeats order id %q", name, i, id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// evaluatePlan totals a fixed selection and checks it against the same
+// capacity and compatibility rules the solver uses
+func evaluatePlan(req, internal *OptimizeRequest, plan []string) PlanResult {
+	opt := baseOptimizer(internal)
+	mask := idMask(internal.Orders, plan)
+	resp := opt.BuildResponse(mask)
+	finishResponse(resp, req)
+	return PlanResult{
+		Feasible: opt.fits(mask) && opt.isValidSubset(mask) && opt.complete(mask),
+		Result:   resp,
+	}
+}
+
+func compareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req CompareRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	optReq := &OptimizeRequest{Truck: req.Truck, Orders: req.Orders, WeightUnit: req.WeightUnit, VolumeUnit: req.VolumeUnit}
+	if err := validateRequest(optReq); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+		return
+	}
+	for _, p := range []struct {
+		name string
+		ids  []string
+	}{{"plan_a", req.PlanA}, {"plan_b", req.PlanB}} {
+		if err := validatePlan(p.name, p.ids, req.Orders); err != nil {
+			writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+			return
+		}
+	}
+
+	internal := toInternalUnits(optReq)
+	a := evaluatePlan(optReq, internal, req.PlanA)
+	b := evaluatePlan(optReq, internal, req.PlanB)
+	writeJSON(w, r, http.StatusOK, diffPlans(a, b, req.Orders))
+}
+
+// diffPlans reports both plans and the change going from A to B. Differing
+// orders are listed in request order so the diff reads predictably.
+func diffPlans(a, b PlanResult, orders []Order) CompareResponse {
+	inA := make(map[string]bool, len(a.Result.SelectedOrderIDs))
+	for _, id := range a.Result.SelectedOrderIDs {
+		inA[id] = true
+	}
+	inB := make(map[string]bool, len(b.Result.SelectedOrderIDs))
+	for _, id := range b.Result.SelectedOrderIDs {
+		inB[id] = true
+	}
+	onlyA, onlyB := []string{}, []string{}
+	for _, o := range orders {
+		if inA[o.ID] && !inB[o.ID] {
+			onlyA = append(onlyA, o.ID)
+		}
+		if inB[o.ID] && !inA[o.ID] {
+			onlyB = append(onlyB, o.ID)
+		}
+	}
+
+	return CompareResponse{
+		PlanA:                         a,
+		PlanB:                         b,
+		PayoutDeltaCents:              b.Result.TotalPayoutCents - a.Result.TotalPayoutCents,
+		UtilizationWeightDeltaPercent: roundTo2Decimals(b.Result.UtilizationWeightPercent - a.Result.UtilizationWeightPercent),
+		UtilizationVolumeDeltaPercent: roundTo2Decimals(b.Result.UtilizationVolumePercent - a.Result.UtilizationVolumePercent),
+		OnlyInA:                       onlyA,
+		OnlyInB:                       onlyB,
+	}
+}
+
+// ComparePricingRequest holds two versions of the same orders, differing
+// in payouts, to see how the optimal plan shifts between pricing formulas
+type ComparePricingRequest struct {
+	Truck      Truck   `json:"truck"`
+	OrdersA    []Order `json:"orders_a"`
+	OrdersB    []Order `json:"orders_b"`
+	WeightUnit string  `json:"weight_unit,omitempty"`
+	VolumeUnit string  `json:"volume_unit,omitempty"`
+}
+
+// sameOrderIDs checks that both versions name the same set of orders
+func sameOrderIDs(a, b []Order) error {
+	if len(a) != len(b) {
+		return fmt.Errorf("orders_a and orders_b must hold the same orders, got %d and %d", len(a), len(b))
+	}
+	ids := make(map[string]bool, len(a))
+	for _, o := range a {
+		ids[o.ID] = true
+	}
+	for i, o := range b {
+		if !ids[o.ID] {
+			return fmt.Errorf("orders_b[%d] has id %q, which isn't in orders_a", i, o.ID)
+		}
+	}
+	return nil
+}
+
+// comparePricingHandler solves both versions of the orders and diffs the
+// optimal plans, in the same shape as /compare
+func comparePricingHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req ComparePricingRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	reqA := &OptimizeRequest{Truck: req.Truck, Orders: req.OrdersA, WeightUnit: req.WeightUnit, VolumeUnit: req.VolumeUnit}
+	reqB := &OptimizeRequest{Truck: req.Truck, Orders: req.OrdersB, WeightUnit: req.WeightUnit, VolumeUnit: req.VolumeUnit}
+	for _, q := range []*OptimizeRequest{reqA, reqB} {
+		if err := validateRequest(q); err != nil {
+			writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+			return
+		}
+	}
+	if err := sameOrderIDs(req.OrdersA, req.OrdersB); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+		return
+	}
+
+	if !acquireSolve(w, r) {
+		return
+	}
+	defer globalSolves.release()
+
+	var plans [2]PlanResult
+	for i, q := range []*OptimizeRequest{reqA, reqB} {
+		result, _, _, err := solveCached(r.Context(), q, cfg.SolverDeadline)
+		if err != nil {
+			writeSolveError(w, r, err)
+			return
+		}
+		plans[i] = PlanResult{Feasible: true, Result: result}
+	}
+	writeJSON(w, r, http.StatusOK, diffPlans(plans[0], plans[1], req.OrdersA))
+}
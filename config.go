@@ -0,0 +1,275 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config holds runtime settings loaded from the environment
+type Config struct {
+	// SolverDeadline bounds the exact DP; zero disables the greedy fallback
+	SolverDeadline time.Duration
+	// AdminAPIKey protects operator endpoints; empty disables them
+	AdminAPIKey string
+	// RejectPastDates rejects orders whose delivery_date is before today (UTC)
+	RejectPastDates bool
+	// MaxBodyBytes caps request bodies, enforced while reading
+	MaxBodyBytes int64
+	// BatchWorkers bounds concurrent solves across all batch requests
+	BatchWorkers int
+	// CorridorMemoryBytes bounds the DP tables corridor solves hold at once;
+	// zero means only BatchWorkers bounds them
+	CorridorMemoryBytes int64
+	// MaxConcurrentSolves caps synchronous solves; zero means no limit
+	MaxConcurrentSolves int
+	// RateLimitPerMinute is how many solve requests each client IP may make
+	// per minute, in bursts of up to RateLimitBurst; zero means no limit
+	RateLimitPerMinute int
+	RateLimitBurst     int
+	// MaxConnections caps open client connections; zero means no limit
+	MaxConnections int
+	// MaxSubsets caps the DP table size (2^orders); larger requests get a 503
+	MaxSubsets int64
+	// ExactMaxOrders is the most orders the automatic chooser sends to the
+	// exact solver, and MimMaxOrders the most it sends to meet-in-the-middle
+	// after that; larger requests are solved greedily
+	ExactMaxOrders int
+	MimMaxOrders   int
+	// SlowSolveThreshold marks /optimize responses that took longer with X-Solve-Slow
+	SlowSolveThreshold time.Duration
+	// EmptyResultTTL is how long results with no selected orders stay cached
+	EmptyResultTTL time.Duration
+	// NonceWindow is how long an X-Nonce is remembered to reject replays
+	NonceWindow time.Duration
+	// NonceMaxEntries bounds the nonce store; new nonces are refused while
+	// it is full of unexpired ones
+	NonceMaxEntries int
+	// SelfTest solves a known request at startup and exits if it's wrong
+	SelfTest bool
+	// DebugEndpoints registers /debug routes; keep it off in production
+	DebugEndpoints bool
+	// WebhookSecret signs job callbacks; empty disables callback_url
+	WebhookSecret string
+	// WebhookTimeout bounds each callback attempt
+	WebhookTimeout time.Duration
+	// WebhookMaxAttempts is how many times a failed callback is tried in all
+	WebhookMaxAttempts int
+	// DefaultCurrency is assumed for orders without a currency
+	DefaultCurrency string
+	// StrictCurrency requires every order to name its currency
+	StrictCurrency bool
+	// RoundingMode is how utilization percentages are rounded to 2 decimals
+	RoundingMode string
+	// PayoutOutlierFactor flags orders whose payout per pound is this many
+	// times off the request's median; zero disables the check
+	PayoutOutlierFactor int
+	// HTTP server timeouts; too low a write timeout cuts off large solves
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+}
+
+// Global config instance
+var cfg = loadConfig()
+
+func loadConfig() Config {
+	c := Config{
+		SolverDeadline:      time.Duration(envInt("SOLVER_DEADLINE_MS", 0)) * time.Millisecond,
+		AdminAPIKey:         os.Getenv("ADMIN_API_KEY"),
+		RejectPastDates:     envBool("REJECT_PAST_DATES", false),
+		MaxBodyBytes:        int64(envInt("MAX_BODY_BYTES", 1<<20)),
+		BatchWorkers:        envInt("BATCH_WORKERS", runtime.NumCPU()),
+		CorridorMemoryBytes: int64(envInt("CORRIDOR_MEMORY_BYTES", 0)),
+		MaxConcurrentSolves: envInt("MAX_CONCURRENT_SOLVES", 0),
+		MaxConnections:      envInt("MAX_CONNECTIONS", 0),
+		RateLimitPerMinute:  envInt("RATE_LIMIT_PER_MINUTE", 0),
+		RateLimitBurst:      envInt("RATE_LIMIT_BURST", 0),
+		MaxSubsets:          int64(envInt("MAX_SUBSETS", 1<<maxOrders)),
+		ExactMaxOrders:      envInt("EXACT_MAX_ORDERS", maxOrders),
+		MimMaxOrders:        envInt("MIM_MAX_ORDERS", maxOrders),
+		SlowSolveThreshold:  time.Duration(envInt("SLOW_SOLVE_MS", 500)) * time.Millisecond,
+		ReadTimeout:         envDuration("READ_TIMEOUT", 5*time.Second),
+		WriteTimeout:        envDuration("WRITE_TIMEOUT", 5*time.Second),
+		IdleTimeout:         envDuration("IDLE_TIMEOUT", 10*time.Second),
+		EmptyResultTTL:      envDuration("EMPTY_RESULT_TTL", cacheTTL),
+		NonceWindow:         envDuration("NONCE_WINDOW", 5*time.Minute),
+		NonceMaxEntries:     envInt("NONCE_MAX_ENTRIES", defaultNonceMaxEntries),
+		SelfTest:            envBool("SELF_TEST", false),
+		DebugEndpoints:      envBool("DEBUG_ENDPOINTS", false),
+		WebhookSecret:       os.Getenv("WEBHOOK_SECRET"),
+		WebhookTimeout:      envDuration("WEBHOOK_TIMEOUT", 5*time.Second),
+		WebhookMaxAttempts:  envInt("WEBHOOK_MAX_ATTEMPTS", 3),
+		DefaultCurrency:     os.Getenv("DEFAULT_CURRENCY"),
+		StrictCurrency:      envBool("STRICT_CURRENCY", false),
+		RoundingMode:        os.Getenv("ROUNDING_MODE"),
+		PayoutOutlierFactor: envInt("PAYOUT_OUTLIER_FACTOR", 0),
+	}
+	if c.BatchWorkers < 1 {
+		log.Printf("invalid BATCH_WORKERS=%d, using 1", c.BatchWorkers)
+		c.BatchWorkers = 1
+	}
+	if c.WebhookMaxAttempts < 1 {
+		log.Printf("invalid WEBHOOK_MAX_ATTEMPTS=%d, using 1", c.WebhookMaxAttempts)
+		c.WebhookMaxAttempts = 1
+	}
+	if c.NonceMaxEntries < 1 {
+		log.Printf("invalid NONCE_MAX_ENTRIES=%d, using %d", c.NonceMaxEntries, defaultNonceMaxEntries)
+		c.NonceMaxEntries = defaultNonceMaxEntries
+	}
+	if c.RateLimitBurst == 0 {
+		c.RateLimitBurst = c.RateLimitPerMinute
+	}
+	if c.ExactMaxOrders < 0 || c.ExactMaxOrders > maxOrders {
+		log.Printf("invalid EXACT_MAX_ORDERS=%d, using %d", c.ExactMaxOrders, maxOrders)
+		c.ExactMaxOrders = maxOrders
+	}
+	// The thresholds must grow from exact to meet-in-the-middle
+	if c.MimMaxOrders > maxMeetInMiddleOrders {
+		log.Printf("invalid MIM_MAX_ORDERS=%d, using %d", c.MimMaxOrders, maxMeetInMiddleOrders)
+		c.MimMaxOrders = maxMeetInMiddleOrders
+	}
+	if c.MimMaxOrders < c.ExactMaxOrders {
+		log.Printf("MIM_MAX_ORDERS=%d is below EXACT_MAX_ORDERS=%d, using %d", c.MimMaxOrders, c.ExactMaxOrders, c.ExactMaxOrders)
+		c.MimMaxOrders = c.ExactMaxOrders
+	}
+	switch {
+	case c.DefaultCurrency == "":
+		c.DefaultCurrency = defaultCurrency
+	case !validCurrencyCode(c.DefaultCurrency):
+		log.Printf("invalid DEFAULT_CURRENCY=%q, using %s", c.DefaultCurrency, defaultCurrency)
+		c.DefaultCurrency = defaultCurrency
+	default:
+		c.DefaultCurrency = strings.ToUpper(c.DefaultCurrency)
+	}
+	switch c.RoundingMode {
+	case "":
+		c.RoundingMode = roundingHalfUp
+	case roundingHalfUp, roundingHalfEven, roundingFloor:
+	default:
+		log.Printf("invalid ROUNDING_MODE=%q, using %s", c.RoundingMode, roundingHalfUp)
+		c.RoundingMode = roundingHalfUp
+	}
+	return c
+}
+
+// orderCap is the most orders a request may have: the exact solver's
+// limit, or MIM_MAX_ORDERS when meet-in-the-middle takes more
+func (c Config) orderCap() int {
+	return max(maxOrders, c.MimMaxOrders)
+}
+
+// Rounding modes for ROUNDING_MODE
+const (
+	roundingHalfUp   = "half_up"
+	roundingHalfEven = "half_even"
+	roundingFloor    = "floor"
+)
+
+// redacted stands in for secrets in the /config output
+const redacted = "[redacted]"
+
+// effective returns the settings this instance is running with, including
+// fixed limits. Secrets only report whether they are set.
+func (c Config) effective() map[string]interface{} {
+	adminKey := ""
+	if c.AdminAPIKey != "" {
+		adminKey = redacted
+	}
+	webhookSecret := ""
+	if c.WebhookSecret != "" {
+		webhookSecret = redacted
+	}
+	return map[string]interface{}{
+		"solver_deadline_ms":    c.SolverDeadline.Milliseconds(),
+		"admin_api_key":         adminKey,
+		"reject_past_dates":     c.RejectPastDates,
+		"max_body_bytes":        c.MaxBodyBytes,
+		"batch_workers":         c.BatchWorkers,
+		"corridor_memory_bytes": c.CorridorMemoryBytes,
+		"max_concurrent_solves": c.MaxConcurrentSolves,
+		"max_connections":       c.MaxConnections,
+		"rate_limit_per_minute": c.RateLimitPerMinute,
+		"rate_limit_burst":      c.RateLimitBurst,
+		"slow_solve_ms":         c.SlowSolveThreshold.Milliseconds(),
+		"max_subsets":           c.MaxSubsets,
+		"exact_max_orders":      c.ExactMaxOrders,
+		"mim_max_orders":        c.MimMaxOrders,
+		"read_timeout":          c.ReadTimeout.String(),
+		"write_timeout":         c.WriteTimeout.String(),
+		"idle_timeout":          c.IdleTimeout.String(),
+		"max_orders":            c.orderCap(),
+		"cache_max_entries":     globalCache.maxSize,
+		"cache_ttl":             cacheTTL.String(),
+		"empty_result_ttl":      c.EmptyResultTTL.String(),
+		"job_ttl":               globalJobs.ttl.String(),
+		"job_pending_ttl":       globalJobs.pendingTTL.String(),
+		"job_max_entries":       globalJobs.maxSize,
+		"nonce_window":          c.NonceWindow.String(),
+		"nonce_max_entries":     c.NonceMaxEntries,
+		"self_test":             c.SelfTest,
+		"debug_endpoints":       c.DebugEndpoints,
+		"webhook_secret":        webhookSecret,
+		"webhook_timeout":       c.WebhookTimeout.String(),
+		"webhook_max_attempts":  c.WebhookMaxAttempts,
+		"default_currency":      c.DefaultCurrency,
+		"strict_currency":       c.StrictCurrency,
+		"rounding_mode":         c.RoundingMode,
+		"payout_outlier_factor": c.PayoutOutlierFactor,
+	}
+}
+
+// configHandler reports the effective runtime settings
+func configHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, cfg.effective())
+}
+
+// envInt reads a non-negative integer env var, falling back to def when unset or invalid
+func envInt(name string, def int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Printf("invalid %s=%q, using default %d", name, v, def)
+		return def
+	}
+	return n
+}
+
+// envDuration reads a Go duration env var such as "30s", falling back to def when unset or invalid
+func envDuration(name string, def time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		log.Printf("invalid %s=%q, using default %s", name, v, def)
+		return def
+	}
+	return d
+}
+
+// envBool reads a boolean env var, falling back to def when unset or invalid
+func envBool(name string, def bool) bool {
+	v := os.Getenv(name)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("invalid %s=%q, using default %t", name, v, def)
+		return def
+	}
+	return b
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// CorridorHeader is the first line of a corridor upload: the truck every
+// corridor is planned for, and the units the orders use
+type CorridorHeader struct {
+	Truck      Truck  `json:"truck"`
+	WeightUnit string `json:"weight_unit,omitempty"`
+	VolumeUnit string `json:"volume_unit,omitempty"`
+}
+
+// CorridorPlan is the plan for one origin/destination pair: a result, or the
+// validation error that kept its orders from being solved
+type CorridorPlan struct {
+	Origin      string            `json:"origin"`
+	Destination string            `json:"destination"`
+	OrderCount  int               `json:"order_count"`
+	Result      *OptimizeResponse `json:"result,omitempty"`
+	Error       string            `json:"error,omitempty"`
+}
+
+type CorridorResponse struct {
+	Plans []CorridorPlan `json:"plans"`
+}
+
+// corridorHandler reads an NDJSON stream of a CorridorHeader followed by one
+// Order per line, grouping orders by origin and destination as they arrive,
+// then plans each corridor on the batch worker pool. Only the decoded orders
+// are kept, never the raw body, and MAX_BODY_BYTES caps the bytes read.
+// With ?sorted=true the orders must arrive grouped by corridor, and each
+// corridor is planned as soon as the next one starts, so only one
+// corridor's orders are held while reading. Each corridor's DP tables are
+// freed once it is planned, and CORRIDOR_MEMORY_BYTES bounds how many bytes
+// of tables exist at once.
+func corridorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+	body, ok := limitedBody(w, r)
+	if !ok {
+		return
+	}
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+
+	// decodeErr reports a bad line, telling oversize bodies apart
+	decodeErr := func(what string, err error) {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge, "payload too large")
+			return
+		}
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidJSON, fmt.Sprintf("invalid JSON in %s: %s", what, err.Error()))
+	}
+
+	var header CorridorHeader
+	if err := decoder.Decode(&header); err != nil {
+		decodeErr("line 1", err)
+		return
+	}
+	base := OptimizeRequest{Truck: header.Truck, WeightUnit: header.WeightUnit, VolumeUnit: header.VolumeUnit}
+	if err := validateRequest(&base); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+		return
+	}
+
+	sorted := r.URL.Query().Get("sorted") == "true"
+
+	// Like a batch, the upload counts as one solve against
+	// MAX_CONCURRENT_SOLVES, held until every corridor is planned
+	if !acquireSolve(w, r) {
+		return
+	}
+	defer globalSolves.release()
+
+	// Plans are pointers since workers fill them in while more corridors
+	// are still being appended
+	var plans []*CorridorPlan
+	var orders [][]Order
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	// dispatch validates corridor i and plans it on the worker pool,
+	// dropping the handler's hold on its orders. A corridor over the order
+	// limit is planned from its densest orders rather than failed.
+	dispatch := func(i int) {
+		plan := plans[i]
+		plan.OrderCount = len(orders[i])
+		req := base
+		req.Orders = orders[i]
+		orders[i] = nil
+		if err := validateRequestFields(&req); err != nil {
+			plan.Error = err.Error()
+			return
+		}
+		limit := cfg.orderCap()
+		shortlisted := len(req.Orders) > limit
+		if shortlisted {
+			req.Orders = shortlist(&req, limit)
+		}
+
+		wg.Add(1)
+		batchSem <- struct{}{}
+		go func() {
+			defer func() { <-batchSem; wg.Done() }()
+			reserved := corridorMemory.acquire(estimate(req.Truck, len(req.Orders)).MemoryBytes)
+			defer corridorMemory.release(reserved)
+			result, _, _, err := solveCached(r.Context(), &req, cfg.SolverDeadline)
+			if err != nil {
+				plan.Error = err.Error()
+				return
+			}
+			if shortlisted {
+				// The result may be shared with the cache, so mark a copy
+				marked := *result
+				marked.Optimal = false
+				marked.Warnings = append(slices.Clone(result.Warnings), fmt.Sprintf("corridor has %d orders; only the %d densest were considered", plan.OrderCount, limit))
+				result = &marked
+			}
+			plan.Result = result
+		}()
+	}
+
+	// Corridors match case-insensitively and keep the first spelling seen,
+	// listed in order of first appearance
+	index := make(map[string]int)
+	for line := 2; ; line++ {
+		var o Order
+		err := decoder.Decode(&o)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			decodeErr(fmt.Sprintf("line %d", line), err)
+			return
+		}
+		key := strings.ToLower(o.Origin) + "\x00" + strings.ToLower(o.Destination)
+		i, ok := index[key]
+		if !ok {
+			if sorted && len(plans) > 0 {
+				dispatch(len(plans) - 1)
+			}
+			i = len(plans)
+			index[key] = i
+			plans = append(plans, &CorridorPlan{Origin: o.Origin, Destination: o.Destination})
+			orders = append(orders, nil)
+		} else if sorted && i != len(plans)-1 {
+			writeError(w, r, http.StatusBadRequest, errCodeInvalidBody, fmt.Sprintf("line %d: corridor %s to %s appeared again after other corridors; sorted uploads must group orders by corridor", line, o.Origin, o.Destination))
+			return
+		}
+		orders[i] = append(orders[i], o)
+	}
+
+	start := 0
+	if sorted {
+		start = max(len(plans)-1, 0)
+	}
+	for i := start; i < len(plans); i++ {
+		dispatch(i)
+	}
+	wg.Wait()
+
+	resp := CorridorResponse{Plans: []CorridorPlan{}}
+	for _, plan := range plans {
+		resp.Plans = append(resp.Plans, *plan)
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// shortlist returns the limit orders of a corridor with the highest payout
+// density in either weight or volume, greedy's default ranking, in their
+// upload order. The solvers' bitmasks can't span more, so the rest are
+// left off the plan.
+func shortlist(req *OptimizeRequest, limit int) []Order {
+	density := func(o Order) float64 {
+		size := min(capacityShare(o.WeightLbs, req.Truck.freeWeightLbs()), capacityShare(o.VolumeCuft, req.Truck.freeVolumeCuft()))
+		return perShare(o.PayoutCents, size)
+	}
+	indexes := make([]int, len(req.Orders))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(a, b int) bool {
+		return density(req.Orders[indexes[a]]) > density(req.Orders[indexes[b]])
+	})
+	indexes = indexes[:limit]
+	slices.Sort(indexes)
+
+	kept := make([]Order, 0, limit)
+	for _, i := range indexes {
+		kept = append(kept, req.Orders[i])
+	}
+	return kept
+}
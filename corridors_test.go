@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// corridorUpload builds an NDJSON corridor body for the test truck with
+// one order per origin/destination pair given
+func corridorUpload(t *testing.T, routes ...[2]string) string {
+	t.Helper()
+	var orders []Order
+	for i, route := range routes {
+		order := testOrder(string(rune('a'+i)), 1000, 1000, 100)
+		order.Origin, order.Destination = route[0], route[1]
+		orders = append(orders, order)
+	}
+	return corridorBody(t, orders...)
+}
+
+// corridorBody builds an NDJSON corridor body for the test truck
+func corridorBody(t *testing.T, orders ...Order) string {
+	t.Helper()
+	lines := []any{CorridorHeader{Truck: testRequest().Truck}}
+	for _, order := range orders {
+		lines = append(lines, order)
+	}
+	var body strings.Builder
+	for _, line := range lines {
+		b, err := json.Marshal(line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body.Write(b)
+		body.WriteByte('\n')
+	}
+	return body.String()
+}
+
+func TestCorridorsSorted(t *testing.T) {
+	laDallas := [2]string{"Los Angeles, CA", "Dallas, TX"}
+	laPhoenix := [2]string{"Los Angeles, CA", "Phoenix, AZ"}
+	tests := []struct {
+		name     string
+		query    string
+		routes   [][2]string
+		wantCode int
+		want     []int // order count per plan
+	}{
+		{"unsorted upload", "", [][2]string{laDallas, laPhoenix, laDallas}, http.StatusOK, []int{2, 1}},
+		{"sorted upload", "?sorted=true", [][2]string{laDallas, laDallas, laPhoenix}, http.StatusOK, []int{2, 1}},
+		{"sorted upload out of order", "?sorted=true", [][2]string{laDallas, laPhoenix, laDallas}, http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/corridors"+tt.query, strings.NewReader(corridorUpload(t, tt.routes...)))
+			w := httptest.NewRecorder()
+			corridorHandler(w, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.want == nil {
+				return
+			}
+			var resp CorridorResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, plan := range resp.Plans {
+				if plan.Result == nil {
+					t.Errorf("%s to %s: no result, error %q", plan.Origin, plan.Destination, plan.Error)
+				}
+				got = append(got, plan.OrderCount)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("order counts %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCorridorsOverOrderLimit(t *testing.T) {
+	saved := cfg.ExactMaxOrders
+	defer func() { cfg.ExactMaxOrders = saved }()
+	cfg.ExactMaxOrders = 0 // keep the shortlist's solve quick
+
+	// Three sparse orders among more dense ones than the limit allows;
+	// every dense order fits at once
+	limit := cfg.orderCap()
+	var orders, dense []Order
+	for i := 0; i < limit+3; i++ {
+		order := testOrder(fmt.Sprintf("ord-%02d", i), 1000, 1000, 10)
+		if i%8 == 3 {
+			order.PayoutCents = 10
+		} else {
+			dense = append(dense, order)
+		}
+		orders = append(orders, order)
+	}
+	if len(dense) != limit {
+		t.Fatalf("%d dense orders, want %d", len(dense), limit)
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/corridors", strings.NewReader(corridorBody(t, orders...)))
+	w := httptest.NewRecorder()
+	corridorHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var resp CorridorResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	plan := resp.Plans[0]
+	if plan.Result == nil {
+		t.Fatalf("no result, error %q", plan.Error)
+	}
+	var want []string
+	for _, order := range dense {
+		want = append(want, order.ID)
+	}
+	if plan.OrderCount != limit+3 || !slices.Equal(plan.Result.SelectedOrderIDs, want) {
+		t.Errorf("order_count %d, selected %v; want %d and the dense orders", plan.OrderCount, plan.Result.SelectedOrderIDs, limit+3)
+	}
+	if plan.Result.Optimal || len(plan.Result.Warnings) == 0 {
+		t.Errorf("optimal %t, warnings %v; want a warned, non-optimal plan", plan.Result.Optimal, plan.Result.Warnings)
+	}
+}
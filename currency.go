@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultCurrency is DEFAULT_CURRENCY when unset or invalid
+const defaultCurrency = "USD"
+
+// validCurrencyCode reports whether code looks like an ISO 4217 code: three
+// ASCII letters. Which codes exist isn't checked.
+func validCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if (c < 'A' || c > 'Z') && (c < 'a' || c > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// validateCurrency resolves each order's currency, filling in
+// DEFAULT_CURRENCY unless STRICT_CURRENCY requires it to be explicit, and
+// stores it upper-cased so the cache sees one spelling. The solver has no
+// exchange rates, so every order must be in the same currency; summing
+// mixed ones would silently produce a meaningless total.
+func validateCurrency(req *OptimizeRequest) error {
+	first := -1
+	for i, o := range req.Orders {
+		switch {
+		case o.Currency == "" && cfg.StrictCurrency:
+			return fmt.Errorf("orders[%d].currency is required", i)
+		case o.Currency == "":
+			req.Orders[i].Currency = cfg.DefaultCurrency
+		case !validCurrencyCode(o.Currency):
+			return fmt.Errorf("orders[%d].currency must be a 3-letter ISO 4217 code", i)
+		default:
+			req.Orders[i].Currency = strings.ToUpper(o.Currency)
+		}
+		if first < 0 {
+			first = i
+		} else if req.Orders[i].Currency != req.Orders[first].Currency {
+			return fmt.Errorf("orders[%d].currency %s differs from orders[%d].currency %s; all orders must share one currency",
+				i, req.Orders[i].Currency, first, req.Orders[first].Currency)
+		}
+	}
+	return nil
+}
+
+// requestCurrency is the currency every amount in a validated request is in
+func requestCurrency(req *OptimizeRequest) string {
+	if len(req.Orders) > 0 {
+		return req.Orders[0].Currency
+	}
+	return cfg.DefaultCurrency
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"net/http"
+	"time"
+)
+
+// maxDebugOrders keeps debug dumps small: 2^10 masks at most
+const maxDebugOrders = 10
+
+// DebugMask is one row of the DP tables. Sums are in lbs and cuft. Masks the
+// solver pruned without visiting (supersets of an invalid subset) keep zero
+// sums and report visited false.
+type DebugMask struct {
+	Mask        int      `json:"mask"`
+	OrderIDs    []string `json:"order_ids"`
+	WeightLbs   Quantity `json:"weight_lbs"`
+	VolumeCuft  Quantity `json:"volume_cuft"`
+	PayoutCents int64    `json:"payout_cents"`
+	Valid       bool     `json:"valid"`
+	Visited     bool     `json:"visited"`
+}
+
+type DebugDPResponse struct {
+	Masks []DebugMask `json:"masks"`
+}
+
+// debugDPHandler dumps the DP tables for a small request, to see why an
+// order wasn't selected. Only registered with DEBUG_ENDPOINTS=true.
+func debugDPHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req OptimizeRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if err := validateRequest(&req); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+		return
+	}
+	if len(req.Orders) > maxDebugOrders {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, fmt.Sprintf("debug dumps allow at most %d orders", maxDebugOrders))
+		return
+	}
+
+	opt, err := newOptimizer(toInternalUnits(&req), time.Time{})
+	if err != nil {
+		writeSolveError(w, r, err)
+		return
+	}
+
+	resp := DebugDPResponse{Masks: make([]DebugMask, 0, opt.maxMask)}
+	for mask := 0; mask < opt.maxMask; mask++ {
+		row := DebugMask{
+			Mask:        mask,
+			OrderIDs:    []string{},
+			WeightLbs:   opt.weight[mask],
+			VolumeCuft:  opt.volume[mask],
+			PayoutCents: opt.payout[mask],
+			Valid:       opt.valid[mask],
+			// precompute reaches a mask only from the valid subset without its top bit
+			Visited: mask == 0 || opt.valid[mask&^(1<<(bits.Len(uint(mask))-1))],
+		}
+		for i := 0; i < opt.n; i++ {
+			if mask&(1<<i) != 0 {
+				row.OrderIDs = append(row.OrderIDs, req.Orders[i].ID)
+			}
+		}
+		resp.Masks = append(resp.Masks, row)
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
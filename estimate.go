@@ -0,0 +1,108 @@
+package main
+
+import "net/http"
+
+// EstimateRequest describes a solve by its size alone
+type EstimateRequest struct {
+	Truck      Truck `json:"truck"`
+	OrderCount int   `json:"order_count"`
+}
+
+// EstimateResponse predicts what /optimize would do with a request of the
+// given size
+type EstimateResponse struct {
+	OrderCount int `json:"order_count"`
+	// 2^order_count, the DP table size
+	Subsets int64 `json:"subsets"`
+	// Memory the DP tables would take; meet-in-the-middle only builds
+	// tables over each half
+	MemoryBytes int64 `json:"memory_bytes"`
+	// The solver /optimize would use, from solverPath: "exact",
+	// "meet_in_middle" or "greedy" past EXACT_MAX_ORDERS, or "rejected"
+	// when the request would be turned away
+	Path string `json:"path"`
+}
+
+// Estimate paths
+const (
+	estimatePathExact        = "exact"
+	estimatePathMeetInMiddle = solverMeetInMiddle
+	estimatePathGreedy       = "greedy"
+	estimatePathRejected     = "rejected"
+)
+
+// Bytes per subset in the DP tables: weight, volume and payout, valid and
+// hazmat flags, origin and destination bitsets, and the shared trip window
+// when the truck has transit_days
+const (
+	subsetBytes         = 8 + 8 + 8 + 1 + 1 + 4 + 4
+	subsetScheduleBytes = 4 + 4
+)
+
+// estimate predicts the DP size and solver path for n orders, from n,
+// EXACT_MAX_ORDERS, MIM_MAX_ORDERS and MAX_SUBSETS alone. Multi-stop
+// requests also allocate the trip window, which this doesn't know about.
+func estimate(truck Truck, n int) EstimateResponse {
+	resp := EstimateResponse{OrderCount: n, Path: solverPath(n, 0)}
+	if n > cfg.orderCap() {
+		return resp
+	}
+	perSubset := int64(subsetBytes)
+	if truck.TransitDays > 0 {
+		perSubset += subsetScheduleBytes
+	}
+	if resp.Path == estimatePathMeetInMiddle {
+		resp.Subsets = meetInMiddleSubsets(n)
+	} else if n <= maxOrders {
+		resp.Subsets = int64(1) << n
+	}
+	resp.MemoryBytes = resp.Subsets * perSubset
+	return resp
+}
+
+// solverPath returns the solver solveWith's chooser takes for n orders
+// when the request forces none: exact, meet-in-the-middle past
+// EXACT_MAX_ORDERS, greedy past MIM_MAX_ORDERS or when the halves' tables
+// would exceed MAX_SUBSETS, or rejected over the order
+// cap (422) or when the exact solver's tables would exceed MAX_SUBSETS
+// (503). override is the request's X-Max-Orders, or 0; it replaces the
+// thresholds and the cap, so up to override orders go to the exact solver
+// and more are rejected, never sent to a weaker solver.
+func solverPath(n, override int) string {
+	exactMax, limit := cfg.ExactMaxOrders, cfg.orderCap()
+	if override > 0 {
+		exactMax, limit = override, override
+	}
+	switch {
+	case n > limit:
+		return estimatePathRejected
+	case n > exactMax && n <= cfg.MimMaxOrders && meetInMiddleSubsets(n) <= cfg.MaxSubsets:
+		return estimatePathMeetInMiddle
+	case n > exactMax:
+		return estimatePathGreedy
+	case int64(1)<<n > subsetLimit(override):
+		return estimatePathRejected
+	}
+	return estimatePathExact
+}
+
+func estimateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req EstimateRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+	if req.OrderCount < 0 {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, "order_count must be non-negative")
+		return
+	}
+	if req.Truck.TransitDays < 0 {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, "truck.transit_days must be non-negative")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, estimate(req.Truck, req.OrderCount))
+}
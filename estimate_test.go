@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEstimateMatchesSolve(t *testing.T) {
+	savedExact, savedMim, savedSubsets := cfg.ExactMaxOrders, cfg.MimMaxOrders, cfg.MaxSubsets
+	defer func() { cfg.ExactMaxOrders, cfg.MimMaxOrders, cfg.MaxSubsets = savedExact, savedMim, savedSubsets }()
+
+	tests := []struct {
+		exactMax, mimMax int
+		maxSubsets       int64
+	}{
+		{maxOrders, maxOrders, 1 << maxOrders},
+		{6, 6, 1 << maxOrders},         // EXACT_MAX_ORDERS binds first
+		{maxOrders, maxOrders, 1 << 4}, // MAX_SUBSETS binds first
+		{6, 6, 1 << 4},
+		{4, 6, 1 << maxOrders}, // meet-in-the-middle between the two
+	}
+	for _, tt := range tests {
+		cfg.ExactMaxOrders, cfg.MimMaxOrders, cfg.MaxSubsets = tt.exactMax, tt.mimMax, tt.maxSubsets
+		for n := 0; n <= 8; n++ {
+			t.Run(fmt.Sprintf("exact_max=%d/mim_max=%d/max_subsets=%d/orders=%d", tt.exactMax, tt.mimMax, tt.maxSubsets, n), func(t *testing.T) {
+				var orders []Order
+				for i := 0; i < n; i++ {
+					orders = append(orders, testOrder(fmt.Sprintf("ord-%d", i), 1000, 1000, 100))
+				}
+				var got string
+				switch resp, err := solve(context.Background(), testRequest(orders...), 0); {
+				case errors.Is(err, errTooManySubsets):
+					got = estimatePathRejected
+				case err != nil:
+					t.Fatal(err)
+				case resp.Optimal && (resp.Diagnostics != nil || n == 0): // no orders skips the tables
+					got = estimatePathExact
+				case resp.Optimal:
+					got = estimatePathMeetInMiddle
+				default:
+					got = estimatePathGreedy
+				}
+				if want := estimate(Truck{}, n).Path; got != want {
+					t.Errorf("solve took %s, estimate says %s", got, want)
+				}
+			})
+		}
+	}
+
+	cfg.ExactMaxOrders, cfg.MimMaxOrders = maxOrders, maxOrders
+	if got := estimate(Truck{}, maxOrders+1).Path; got != estimatePathRejected {
+		t.Errorf("over the order cap: path %s, want %s", got, estimatePathRejected)
+	}
+}
+
+func TestMeetInMiddleChooser(t *testing.T) {
+	savedExact, savedMim := cfg.ExactMaxOrders, cfg.MimMaxOrders
+	defer func() { cfg.ExactMaxOrders, cfg.MimMaxOrders = savedExact, savedMim }()
+	cfg.ExactMaxOrders, cfg.MimMaxOrders = maxOrders, 30
+
+	// Past maxOrders the exact solver can't run, but meet-in-the-middle can
+	var orders []Order
+	for i := 0; i < 26; i++ {
+		orders = append(orders, testOrder(fmt.Sprintf("ord-%02d", i), 5000+int64(i)*100, 400, 100*int64(i+1)))
+	}
+	req := testRequest(orders...)
+	resp := mustSolve(t, req)
+	if !resp.Optimal || resp.Diagnostics != nil {
+		t.Errorf("optimal %t, diagnostics %v; want a meet-in-the-middle answer", resp.Optimal, resp.Diagnostics)
+	}
+	if got := estimate(req.Truck, len(orders)); got.Path != estimatePathMeetInMiddle || got.Subsets != 2<<13 {
+		t.Errorf("estimate = %+v, want meet_in_middle over 2^13 subsets a half", got)
+	}
+
+	// It only maximizes the total score, so other objectives go greedy
+	req.Objective = objectiveMaximinMargin
+	if resp := mustSolve(t, req); resp.Optimal {
+		t.Errorf("objective %s: optimal, want a greedy answer", req.Objective)
+	}
+
+	// Over MIM_MAX_ORDERS the request is turned away
+	for i := 26; i < 31; i++ {
+		orders = append(orders, testOrder(fmt.Sprintf("ord-%02d", i), 1000, 100, 100))
+	}
+	if err := validateRequest(testRequest(orders...)); err == nil || !strings.Contains(err.Error(), "too many orders (max 30)") {
+		t.Errorf("31 orders: err %v, want too many orders", err)
+	}
+}
+
+func TestMimMaxOrdersConfig(t *testing.T) {
+	tests := []struct {
+		exact, mim string
+		wantMim    int
+	}{
+		{"", "", maxOrders},
+		{"10", "30", 30},
+		{"10", "99", maxMeetInMiddleOrders},
+		{"20", "12", 20}, // never below EXACT_MAX_ORDERS
+	}
+	for _, tt := range tests {
+		t.Run(tt.exact+"/"+tt.mim, func(t *testing.T) {
+			t.Setenv("EXACT_MAX_ORDERS", tt.exact)
+			t.Setenv("MIM_MAX_ORDERS", tt.mim)
+			c := loadConfig()
+			if c.MimMaxOrders != tt.wantMim {
+				t.Errorf("MimMaxOrders = %d, want %d", c.MimMaxOrders, tt.wantMim)
+			}
+			if want := max(maxOrders, tt.wantMim); c.orderCap() != want {
+				t.Errorf("orderCap = %d, want %d", c.orderCap(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// bindingCompatibility is the feasibility binding constraint for a load
+// within capacity that breaks a hazmat, route, schedule, pairing or
+// category rule
+const bindingCompatibility = "compatibility"
+
+// FeasibilityResponse answers ?feasibility=true: whether every order fits
+// on the truck as one load
+type FeasibilityResponse struct {
+	Feasible bool `json:"feasible"`
+	// What rules the full load out: "weight" or "volume" when it's over
+	// capacity (the fuller one if both), "compatibility" when it fits but
+	// breaks a compatibility rule, "none" when it's feasible
+	BindingConstraint string `json:"binding_constraint"`
+}
+
+// feasibility checks the load of every order in one pass over the orders,
+// without the DP tables or a bitmask, so it answers for requests of any size
+func feasibility(req *OptimizeRequest) FeasibilityResponse {
+	weightCap, volumeCap := req.Truck.weightCap(), req.Truck.freeVolumeCuft()
+	if req.IgnoreWeight {
+		weightCap = math.MaxInt64
+	}
+	if req.IgnoreVolume {
+		volumeCap = math.MaxInt64
+	}
+
+	// Totals saturate rather than wrap; anything that large is over
+	// capacity either way
+	var weight, volume Quantity
+	axles := make([]Quantity, len(req.Truck.AxleCapacities))
+	var hasHazmat, hasNonHazmat bool
+	latestPickup, earliestDelivery := int64(math.MinInt64), int64(math.MaxInt64)
+	for _, order := range req.Orders {
+		weight = addSaturating(weight, order.WeightLbs)
+		volume = addSaturating(volume, order.VolumeCuft)
+		if len(axles) > 0 {
+			axles[order.AxlePosition] = addSaturating(axles[order.AxlePosition], order.WeightLbs)
+		}
+		if order.IsHazmat {
+			hasHazmat = true
+		} else {
+			hasNonHazmat = true
+		}
+		pickup, _ := time.Parse("2006-01-02", order.PickupDate)
+		delivery, _ := time.Parse("2006-01-02", order.DeliveryDate)
+		latestPickup = max(latestPickup, pickup.Unix()/86400)
+		earliestDelivery = min(earliestDelivery, delivery.Unix()/86400)
+	}
+
+	overWeight, overVolume := weight > weightCap, volume > volumeCap
+	switch {
+	case overWeight && overVolume:
+		if float64(volume)/float64(volumeCap) > float64(weight)/float64(weightCap) {
+			return FeasibilityResponse{BindingConstraint: bindingVolume}
+		}
+		return FeasibilityResponse{BindingConstraint: bindingWeight}
+	case overWeight:
+		return FeasibilityResponse{BindingConstraint: bindingWeight}
+	case overVolume:
+		return FeasibilityResponse{BindingConstraint: bindingVolume}
+	}
+
+	incompatible := len(req.IncompatiblePairs) > 0 ||
+		hasHazmat && (hasNonHazmat || !req.Truck.allowsHazmat())
+	for axle, load := range axles {
+		incompatible = incompatible || load > req.Truck.AxleCapacities[axle]
+	}
+
+	// Route: distinct stops, and orders per destination
+	originIDs := placeIDs(req.Orders, func(order Order) string { return order.Origin })
+	destIDs := placeIDs(req.Orders, func(order Order) string { return order.Destination })
+	origins, dests := 0, 0
+	perDest := make(map[int32]int)
+	for i := range req.Orders {
+		origins = max(origins, int(originIDs[i])+1)
+		dests = max(dests, int(destIDs[i])+1)
+		perDest[destIDs[i]]++
+		if req.MaxOrdersPerDestination > 0 && perDest[destIDs[i]] > req.MaxOrdersPerDestination {
+			incompatible = true
+		}
+	}
+	if origins > stopLimit(req.AllowMultiOrigin, req.MaxOrigins) || dests > stopLimit(req.AllowMultiDestination, req.MaxDestinations) {
+		incompatible = true
+	}
+
+	// The shared trip must make every delivery date
+	if (req.Truck.TransitDays > 0 || origins > 1 || dests > 1) && latestPickup+req.Truck.TransitDays > earliestDelivery {
+		incompatible = true
+	}
+
+	// No category may hold more than max_category_percent of the weight.
+	// Every order is in the load, so order_groups always ship whole.
+	if req.MaxCategoryPercent > 0 && weight > 0 {
+		categoryIDs := placeIDs(req.Orders, func(order Order) string { return order.Category })
+		perCategory := make(map[int32]Quantity)
+		for i, order := range req.Orders {
+			if order.Category != "" {
+				perCategory[categoryIDs[i]] = addSaturating(perCategory[categoryIDs[i]], order.WeightLbs)
+			}
+		}
+		for _, categoryWeight := range perCategory {
+			if float64(categoryWeight)*100 > req.MaxCategoryPercent*float64(weight) {
+				incompatible = true
+			}
+		}
+	}
+
+	if incompatible {
+		return FeasibilityResponse{BindingConstraint: bindingCompatibility}
+	}
+	return FeasibilityResponse{Feasible: true, BindingConstraint: bindingNone}
+}
+
+// addSaturating adds two non-negative quantities, stopping at the largest
+// Quantity instead of wrapping around
+func addSaturating(a, b Quantity) Quantity {
+	if a > math.MaxInt64-b {
+		return math.MaxInt64
+	}
+	return a + b
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFeasibility(t *testing.T) {
+	// Three orders on one corridor that fit together
+	tests := []struct {
+		name   string
+		modify func(req *OptimizeRequest)
+		want   FeasibilityResponse
+	}{
+		{"fits", func(req *OptimizeRequest) {}, FeasibilityResponse{Feasible: true, BindingConstraint: bindingNone}},
+		{"over weight", func(req *OptimizeRequest) { req.Orders[0].WeightLbs = wholeQuantity(41000) }, FeasibilityResponse{BindingConstraint: bindingWeight}},
+		{"over volume", func(req *OptimizeRequest) { req.Orders[0].VolumeCuft = wholeQuantity(2900) }, FeasibilityResponse{BindingConstraint: bindingVolume}},
+		{"over both, volume fuller", func(req *OptimizeRequest) {
+			req.Orders[0].WeightLbs = wholeQuantity(41000)
+			req.Orders[0].VolumeCuft = wholeQuantity(6000)
+		}, FeasibilityResponse{BindingConstraint: bindingVolume}},
+		{"ignored dimension", func(req *OptimizeRequest) {
+			req.Orders[0].VolumeCuft = wholeQuantity(2900)
+			req.IgnoreVolume = true
+		}, FeasibilityResponse{Feasible: true, BindingConstraint: bindingNone}},
+		{"hazmat mix", func(req *OptimizeRequest) { req.Orders[0].IsHazmat = true }, FeasibilityResponse{BindingConstraint: bindingCompatibility}},
+		{"two destinations", func(req *OptimizeRequest) { req.Orders[0].Destination = "Phoenix, AZ" }, FeasibilityResponse{BindingConstraint: bindingCompatibility}},
+		{"multi-drop allowed", func(req *OptimizeRequest) {
+			req.Orders[0].Destination = "Phoenix, AZ"
+			req.AllowMultiDestination = true
+		}, FeasibilityResponse{Feasible: true, BindingConstraint: bindingNone}},
+		{"places match case-insensitively", func(req *OptimizeRequest) { req.Orders[0].Destination = " dallas, tx" }, FeasibilityResponse{Feasible: true, BindingConstraint: bindingNone}},
+		{"per-destination cap", func(req *OptimizeRequest) { req.MaxOrdersPerDestination = 2 }, FeasibilityResponse{BindingConstraint: bindingCompatibility}},
+		{"incompatible pair", func(req *OptimizeRequest) { req.IncompatiblePairs = [][]string{{"a", "c"}} }, FeasibilityResponse{BindingConstraint: bindingCompatibility}},
+		{"transit misses a delivery", func(req *OptimizeRequest) { req.Truck.TransitDays = 5 }, FeasibilityResponse{BindingConstraint: bindingCompatibility}},
+		{"axle over capacity", func(req *OptimizeRequest) {
+			req.Truck.AxleCapacities = []Quantity{wholeQuantity(5000), wholeQuantity(30000)}
+		}, FeasibilityResponse{BindingConstraint: bindingCompatibility}},
+		{"category within its share", func(req *OptimizeRequest) {
+			req.Orders[0].Category, req.Orders[1].Category = "dry", "DRY"
+			req.MaxCategoryPercent = 70
+		}, FeasibilityResponse{Feasible: true, BindingConstraint: bindingNone}},
+		{"category over its share", func(req *OptimizeRequest) {
+			req.Orders[0].Category, req.Orders[1].Category = "dry", "DRY"
+			req.MaxCategoryPercent = 60
+		}, FeasibilityResponse{BindingConstraint: bindingCompatibility}},
+		{"group ships whole", func(req *OptimizeRequest) { req.OrderGroups = [][]string{{"a", "b"}} }, FeasibilityResponse{Feasible: true, BindingConstraint: bindingNone}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(testOrder("a", 1000, 2000, 100), testOrder("b", 1000, 2000, 100), testOrder("c", 1000, 2000, 100))
+			tt.modify(req)
+			if err := validateRequestFields(req); err != nil {
+				t.Fatalf("validateRequestFields: %v", err)
+			}
+			if got := feasibility(toInternalUnits(req)); got != tt.want {
+				t.Errorf("feasibility = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFeasibilityIgnoresOrderCap(t *testing.T) {
+	// Far more orders than any bitmask over them could hold
+	var orders []Order
+	for i := 0; i < 100; i++ {
+		orders = append(orders, testOrder(fmt.Sprintf("ord-%03d", i), 1000, 100, 10))
+	}
+	body, err := json.Marshal(testRequest(orders...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		query    string
+		wantCode int
+	}{
+		{"?feasibility=true", http.StatusOK},
+		{"", http.StatusUnprocessableEntity},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/optimize"+tt.query, bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentTypeJSON)
+		w := httptest.NewRecorder()
+		optimizeHandler(w, r)
+		if w.Code != tt.wantCode {
+			t.Fatalf("%q: status %d, want %d: %s", tt.query, w.Code, tt.wantCode, w.Body)
+		}
+		if tt.wantCode != http.StatusOK {
+			continue
+		}
+		var got FeasibilityResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatal(err)
+		}
+		if !got.Feasible {
+			t.Errorf("%q: %+v, want feasible", tt.query, got)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+)
+
+// FindGreedy picks orders in descending payout density, adding each one that
+// keeps the load within capacity and compatible. It doesn't need the DP tables,
+// so it runs in O(n^2) and is used when the exact solver runs out of time.
+// Orders in an all-or-nothing group are added or skipped together. A load
+// over max_category_percent has its latest orders of that category dropped.
+// Ties go to the earlier order, or to a seeded shuffle of the orders when
+// the request has a seed, so results are always reproducible.
+// The result is not guaranteed to be optimal.
+func (o *Optimizer) FindGreedy() int {
+	units := o.greedyUnits()
+	if o.seed != nil {
+		// The stable sort below keeps shuffled ties in this order
+		r := rand.New(rand.NewPCG(uint64(*o.seed), 0))
+		r.Shuffle(len(units), func(a, b int) { units[a], units[b] = units[b], units[a] })
+	}
+	sort.SliceStable(units, func(a, b int) bool {
+		return o.density(units[a]) > o.density(units[b])
+	})
+
+	bestMask := 0
+	var added []int
+	for _, unit := range units {
+		next := bestMask | unit
+		if o.fits(next) && o.isValidSubset(next) {
+			bestMask = next
+			added = append(added, unit)
+		}
+	}
+	bestMask = o.balanceCategories(bestMask, added)
+
+	// Guard against the classic greedy failure where one large, high-paying
+	// order is crowded out by several dense small ones.
+	bestScore := o.score(bestMask)
+	for _, unit := range units {
+		if !o.fits(unit) || !o.isValidSubset(unit) || o.overweightCategory(unit) != 0 {
+			continue
+		}
+		if score := o.score(unit); score > bestScore {
+			bestScore = score
+			bestMask = unit
+		}
+	}
+
+	return bestMask
+}
+
+// greedyUnits returns the masks greedy adds as a whole: each order on its
+// own, merged with any order groups it belongs to (transitively)
+func (o *Optimizer) greedyUnits() []int {
+	var units []int
+	seen := 0
+	for i := 0; i < o.n; i++ {
+		if seen&(1<<i) != 0 {
+			continue
+		}
+		unit := 1 << i
+		for changed := true; changed; {
+			changed = false
+			for _, g := range o.groups {
+				if unit&g != 0 && unit|g != unit {
+					unit |= g
+					changed = true
+				}
+			}
+		}
+		seen |= unit
+		units = append(units, unit)
+	}
+	return units
+}
+
+// fits reports whether a mask is within the truck's capacity
+func (o *Optimizer) fits(mask int) bool {
+	_, weight, volume := o.maskTotals(mask)
+	return weight <= o.weightCap && volume <= o.volumeCap
+}
+
+// Greedy sort orders a request can choose with greedy_sort
+const (
+	greedySortPayoutPerWeight = "payout_per_weight"
+	greedySortPayoutPerVolume = "payout_per_volume"
+	greedySortPayout          = "payout"
+)
+
+// density returns the greedy sort key for a mask. By default that is the
+// higher of its score per share of weight and per share of volume, so a
+// mask dense in either dimension ranks high; greedy_sort can pick score per
+// weight, per volume, or the score alone instead.
+func (o *Optimizer) density(mask int) float64 {
+	_, weight, volume := o.maskTotals(mask)
+	payout := o.score(mask)
+	// An ignored dimension is free, like an order that takes none of it
+	var weightShare, volumeShare float64
+	if !o.ignoreWeight {
+		weightShare = capacityShare(weight, o.truck.freeWeightLbs())
+	}
+	if !o.ignoreVolume {
+		volumeShare = capacityShare(volume, o.truck.freeVolumeCuft())
+	}
+
+	var size float64
+	switch o.greedySort {
+	case greedySortPayoutPerWeight:
+		size = weightShare
+	case greedySortPayoutPerVolume:
+		size = volumeShare
+	case greedySortPayout:
+		return float64(payout)
+	default:
+		// The higher density is over the smaller share. An ignored
+		// dimension has no density, so the other one decides.
+		switch {
+		case o.ignoreWeight:
+			size = volumeShare
+		case o.ignoreVolume:
+			size = weightShare
+		default:
+			size = min(weightShare, volumeShare)
+		}
+	}
+	return perShare(payout, size)
+}
+
+// perShare is payout per share of capacity. A share of zero is free to
+// carry, so it ranks above any paying share.
+func perShare(payout int64, size float64) float64 {
+	if size == 0 {
+		return float64(payout) * 1e18
+	}
+	return float64(payout) / size
+}
+
+// capacityShare is the share of free capacity that used takes. A truck
+// preloaded to its rating has none free: any use at all is an infinite
+// share and none is no share, rather than a division by zero.
+func capacityShare(used, free Quantity) float64 {
+	switch {
+	case used == 0:
+		return 0
+	case free <= 0:
+		return math.Inf(1)
+	}
+	return float64(used) / float64(free)
+}
+
+// score is the payout plus any co-load and preference bonuses, less any
+// overage penalty: the value solvers maximize
+func (o *Optimizer) score(mask int) int64 {
+	payout, weight, _ := o.maskTotals(mask)
+	return payout + o.coLoadBonus(mask) + o.bonus(mask) - o.overagePenalty(weight)
+}
+
+// maskTotals sums payout, weight and volume for a mask without the DP tables
+func (o *Optimizer) maskTotals(mask int) (payout int64, weight, volume Quantity) {
+	for i := 0; i < o.n; i++ {
+		if mask&(1<<i) != 0 {
+			payout += o.orders[i].PayoutCents
+			weight = addSaturating(weight, o.orders[i].WeightLbs)
+			volume = addSaturating(volume, o.orders[i].VolumeCuft)
+		}
+	}
+	return payout, weight, volume
+}
+
+// payoutUpperBound bounds the optimal payout from above with the LP
+// relaxation of the knapsack: take orders by payout density, splitting the
+// last one to fill capacity exactly. Each capacity dimension alone gives a
+// valid bound (compatibility and groups only lower the optimum), so the
+// tighter of the two is returned. Co-load bonuses are added in full, as if
+// every pair could be earned at once.
+func (o *Optimizer) payoutUpperBound() float64 {
+	byWeight := o.fractionalBound(o.weightCap, func(order Order) Quantity { return order.WeightLbs })
+	byVolume := o.fractionalBound(o.volumeCap, func(order Order) Quantity { return order.VolumeCuft })
+	var coLoad int64
+	for _, cents := range o.coLoadCents {
+		coLoad += cents
+	}
+	return math.Min(byWeight, byVolume) + float64(coLoad)
+}
+
+// fractionalBound solves the fractional knapsack for one capacity dimension
+func (o *Optimizer) fractionalBound(capacity Quantity, size func(Order) Quantity) float64 {
+	var candidates []Order
+	for _, order := range o.orders {
+		// Orders that can never fit don't contribute to any feasible load
+		if order.WeightLbs > o.weightCap || order.VolumeCuft > o.volumeCap {
+			continue
+		}
+		candidates = append(candidates, order)
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		// payout_a/size_a > payout_b/size_b without dividing by zero
+		return float64(candidates[a].PayoutCents)*float64(size(candidates[b])) >
+			float64(candidates[b].PayoutCents)*float64(size(candidates[a]))
+	})
+
+	bound := 0.0
+	remaining := float64(capacity)
+	for _, order := range candidates {
+		s := float64(size(order))
+		if s <= remaining {
+			bound += float64(order.PayoutCents)
+			remaining -= s
+			continue
+		}
+		bound += float64(order.PayoutCents) * remaining / s
+		break
+	}
+	return bound
+}
+
+// optimalityBoundPercent reports payout as a percentage of the upper bound
+func (o *Optimizer) optimalityBoundPercent(payout int64) float64 {
+	bound := o.payoutUpperBound()
+	if bound <= 0 {
+		return 100
+	}
+	return roundTo2Decimals(math.Min(100, float64(payout)/bound*100))
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPayoutUpperBound(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(req *OptimizeRequest)
+	}{
+		{"plain", func(req *OptimizeRequest) {}},
+		{"co-load bonus", func(req *OptimizeRequest) {
+			req.CoLoadBonuses = []CoLoadBonus{{OrderA: "ord-00", OrderB: "ord-01", BonusCents: 3000}}
+		}},
+		{"ignore volume", func(req *OptimizeRequest) { req.IgnoreVolume = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := uint64(1); seed <= 50; seed++ {
+				req := testRequest(randomOrders(rand.New(rand.NewPCG(seed, 0)), 12)...)
+				req.AllowMultiOrigin, req.AllowMultiDestination = true, true
+				tt.modify(req)
+				if err := validateRequest(req); err != nil {
+					t.Fatalf("seed %d: validateRequest: %v", seed, err)
+				}
+				internal := toInternalUnits(req)
+				bound := baseOptimizer(internal).payoutUpperBound()
+
+				greedy := greedyResponse(internal)
+				if float64(greedy.TotalPayoutCents) > bound {
+					t.Errorf("seed %d: greedy payout %d is above the bound %.2f", seed, greedy.TotalPayoutCents, bound)
+				}
+				if p := *greedy.OptimalityBoundPercent; p < 0 || p > 100 {
+					t.Errorf("seed %d: optimality_bound_percent = %v", seed, p)
+				}
+
+				// The bound is on the optimum, not just on greedy
+				opt, err := newOptimizer(internal, time.Time{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if exact := opt.BuildResponse(opt.FindOptimal()); float64(exact.TotalPayoutCents) > bound {
+					t.Errorf("seed %d: optimal payout %d is above the bound %.2f", seed, exact.TotalPayoutCents, bound)
+				}
+			}
+		})
+	}
+}
+
+func TestGreedySort(t *testing.T) {
+	order := func(id string, payout, weight, volume int64) Order {
+		return Order{
+			ID:           id,
+			PayoutCents:  payout,
+			WeightLbs:    wholeQuantity(weight),
+			VolumeCuft:   wholeQuantity(volume),
+			Origin:       "Los Angeles, CA",
+			Destination:  "Dallas, TX",
+			PickupDate:   "2025-12-05",
+			DeliveryDate: "2025-12-09",
+		}
+	}
+	// On a 10000 lb, 1000 cuft truck each sort key fills it differently:
+	// a and b are dense in one dimension only, which the default's higher
+	// density favours, c and d are moderately dense in both, and e pays the
+	// most but is large in both
+	orders := []Order{
+		order("a", 1000, 6000, 100),
+		order("b", 1000, 1000, 600),
+		order("c", 1200, 5000, 500),
+		order("d", 700, 3500, 350),
+		order("e", 1300, 7000, 700),
+	}
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"", []string{"a", "b"}},
+		{greedySortPayoutPerWeight, []string{"b", "d"}},
+		{greedySortPayoutPerVolume, []string{"a", "d"}},
+		{greedySortPayout, []string{"e"}},
+	}
+	for _, tt := range tests {
+		t.Run("sort="+tt.sort, func(t *testing.T) {
+			o := baseOptimizer(&OptimizeRequest{
+				Truck:      Truck{ID: "truck-1", MaxWeightLbs: wholeQuantity(10000), MaxVolumeCuft: wholeQuantity(1000)},
+				Orders:     orders,
+				GreedySort: tt.sort,
+			})
+			mask := o.FindGreedy()
+			var got []string
+			for i, order := range orders {
+				if mask&(1<<i) != 0 {
+					got = append(got, order.ID)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("selected %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
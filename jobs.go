@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Job statuses
+const (
+	jobPending = "pending"
+	jobDone    = "done"
+	jobError   = "error"
+)
+
+// JobResponse is returned when creating or polling an async optimization job
+type JobResponse struct {
+	JobID  string            `json:"job_id"`
+	Status string            `json:"status"`
+	Result *OptimizeResponse `json:"result,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// job tracks a single async optimization
+type job struct {
+	id string
+	// jobKey of the request, to tell a retry from a conflicting reuse of a
+	// client-chosen ID
+	key        string
+	status     string
+	result     *OptimizeResponse
+	err        string
+	expiration time.Time
+}
+
+// In-memory store for async jobs. Finished jobs are dropped after ttl.
+// Pending jobs that haven't finished after pendingTTL, such as one whose
+// worker stalled, are failed and then dropped like any other. At most
+// maxSize jobs are held, since PUT lets clients pick any number of IDs.
+type jobStore struct {
+	mu         sync.RWMutex
+	jobs       map[string]*job
+	ttl        time.Duration
+	pendingTTL time.Duration
+	maxSize    int
+}
+
+// Global job store instance: finished jobs are kept for 15 minutes, and a
+// solve still running after an hour is given up on
+var globalJobs = newJobStore(15*time.Minute, time.Hour, 10000)
+
+func newJobStore(ttl, pendingTTL time.Duration, maxSize int) *jobStore {
+	return &jobStore{
+		jobs:       make(map[string]*job),
+		ttl:        ttl,
+		pendingTTL: pendingTTL,
+		maxSize:    maxSize,
+	}
+}
+
+// errJobStoreFull means the store already holds maxSize jobs
+var errJobStoreFull = errors.New("too many jobs in progress or awaiting pickup, retry later")
+
+// add registers a pending job under id, or returns errJobStoreFull.
+// s.mu must be held.
+func (s *jobStore) add(id, key string) error {
+	if len(s.jobs) >= s.maxSize {
+		return errJobStoreFull
+	}
+	s.jobs[id] = &job{id: id, key: key, status: jobPending, expiration: now().Add(s.pendingTTL)}
+	return nil
+}
+
+// create registers a new pending job and returns its ID
+func (s *jobStore) create(key string) (string, error) {
+	id := randomID()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return id, s.add(id, key)
+}
+
+// errJobConflict means a job ID is already taken by a different request
+var errJobConflict = errors.New("job id is already used for a different request")
+
+// existing returns the state of the job under id and true if there is one
+// for the same request, or errJobConflict if it was for a different one.
+// s.mu must be held.
+func (s *jobStore) existing(id, key string) (JobResponse, bool, error) {
+	j, exists := s.jobs[id]
+	if !exists {
+		return JobResponse{}, false, nil
+	}
+	if j.key == "" || j.key != key {
+		return JobResponse{}, false, errJobConflict
+	}
+	return JobResponse{JobID: j.id, Status: j.status, Result: j.result, Error: j.err}, true, nil
+}
+
+// lookup is existing for callers that don't hold s.mu, so a retry can be
+// answered before a solve slot is taken for it
+func (s *jobStore) lookup(id, key string) (JobResponse, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.existing(id, key)
+}
+
+// createWithID registers a pending job under a client-chosen ID and reports
+// true. If a job with that ID exists for the same request it returns that
+// job's state and false instead, so retries never start a second solve.
+func (s *jobStore) createWithID(id, key string) (JobResponse, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if resp, found, err := s.existing(id, key); found || err != nil {
+		return resp, false, err
+	}
+	if err := s.add(id, key); err != nil {
+		return JobResponse{}, false, err
+	}
+	return JobResponse{JobID: id, Status: jobPending}, true, nil
+}
+
+// jobKey hashes everything a job was submitted with: the whole decoded
+// request and its callback URL. Unlike cacheKey it keeps fields that don't
+// change the answer, since a PUT reusing an ID with any of them changed is
+// a different submission, not a retry.
+func jobKey(req *OptimizeRequest, callback string) (string, error) {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write(data)
+	// JSON never holds a raw newline, so the two parts can't run together
+	fmt.Fprintf(h, "\n%s", callback)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// finish records the outcome of a job and starts its TTL. A job cleanup
+// already failed for running too long keeps that outcome.
+func (s *jobStore) finish(id string, result *OptimizeResponse, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	j, exists := s.jobs[id]
+	if !exists || j.status != jobPending {
+		return
+	}
+	if errMsg != "" {
+		j.status = jobError
+		j.err = errMsg
+	} else {
+		j.status = jobDone
+		j.result = result
+	}
+	j.expiration = now().Add(s.ttl)
+}
+
+// get returns a snapshot of the job state
+func (s *jobStore) get(id string) (JobResponse, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	j, exists := s.jobs[id]
+	if !exists {
+		return JobResponse{}, false
+	}
+	return JobResponse{JobID: j.id, Status: j.status, Result: j.result, Error: j.err}, true
+}
+
+// cleanup removes finished jobs whose TTL has passed, and fails pending
+// jobs past pendingTTL, which are then kept for ttl so pollers learn why
+func (s *jobStore) cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := now()
+	for id, j := range s.jobs {
+		switch {
+		case !t.After(j.expiration):
+		case j.status == jobPending:
+			j.status = jobError
+			j.err = fmt.Sprintf("job did not finish within %s", s.pendingTTL)
+			j.expiration = t.Add(s.ttl)
+		default:
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// cleanupLoop periodically evicts expired jobs
+func (s *jobStore) cleanupLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.cleanup()
+	}
+}
+
+// runJob solves the request in the background and records the result.
+// ctx is the creating request's context, kept for its request ID and trace
+// but not its cancellation, since the job outlives the request. A
+// non-empty callback is then sent the finished job. The caller must hold a
+// globalSolves slot, which runJob releases once the solve is over, before
+// the callback is sent.
+func runJob(ctx context.Context, id string, req *OptimizeRequest, callback string) {
+	requestID := requestIDFrom(ctx)
+	if callback != "" {
+		// Deferred first so it runs last, after a panic has been recorded
+		defer func() {
+			if resp, found := globalJobs.get(id); found {
+				deliverWebhook(callback, requestID, resp)
+			}
+		}()
+	}
+	defer globalSolves.release()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("request_id=%s job_id=%s solver panicked: %v", requestID, id, r)
+			globalJobs.finish(id, nil, fmt.Sprintf("solver failed: %v", r))
+		}
+	}()
+
+	resp, err := solve(ctx, req, cfg.SolverDeadline)
+	if err != nil {
+		globalJobs.finish(id, nil, err.Error())
+		return
+	}
+	globalJobs.finish(id, resp, "")
+}
+
+func createJobHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	callback, err := callbackURL(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidQuery, err.Error())
+		return
+	}
+	req, ok := decodeOptimizeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Jobs count against MAX_CONCURRENT_SOLVES like synchronous solves, so
+	// a full server turns them away now rather than queuing them
+	if !acquireSolve(w, r) {
+		return
+	}
+
+	// A request that can't be hashed just goes without; it can only be
+	// matched by a later PUT if it has a key
+	key, _ := jobKey(req, callback)
+	id, err := globalJobs.create(key)
+	if err != nil {
+		globalSolves.release()
+		writeJobStoreFull(w, r)
+		return
+	}
+	go runJob(context.WithoutCancel(r.Context()), id, req, callback)
+
+	w.Header().Set("Location", "/api/v1/load-optimizer/jobs/"+id)
+	writeJSON(w, r, http.StatusAccepted, JobResponse{JobID: id, Status: jobPending})
+}
+
+// maxJobIDLength bounds client-chosen job IDs
+const maxJobIDLength = 128
+
+// validJobID reports whether id is a usable client-chosen job ID: letters,
+// digits, '-', '_' and '.', so it is safe in a URL path and in logs
+func validJobID(id string) bool {
+	if id == "" || len(id) > maxJobIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_', c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// jobHandler serves one job: GET polls it, PUT submits it under that ID
+func jobHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		getJobHandler(w, r)
+	case http.MethodPut:
+		rateLimited(rejectReplays(putJobHandler))(w, r)
+	default:
+		methodNotAllowed(w, r, "GET, PUT")
+	}
+}
+
+// putJobHandler starts a job under a client-chosen ID, or returns the
+// existing job when the same request was already submitted under it
+func putJobHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if !validJobID(id) {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation,
+			fmt.Sprintf("job id must be 1 to %d letters, digits, '-', '_' or '.'", maxJobIDLength))
+		return
+	}
+
+	callback, err := callbackURL(r)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidQuery, err.Error())
+		return
+	}
+	req, ok := decodeOptimizeRequest(w, r)
+	if !ok {
+		return
+	}
+	key, err := jobKey(req, callback)
+	if err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, "request can't be compared with other jobs: "+err.Error())
+		return
+	}
+
+	// A retry or a conflict is answered without a solve slot, so a full
+	// server still tells the client where its job stands
+	resp, found, err := globalJobs.lookup(id, key)
+	if err != nil {
+		writeError(w, r, http.StatusConflict, errCodeJobConflict, err.Error())
+		return
+	}
+	if found {
+		writeJSON(w, r, http.StatusOK, resp)
+		return
+	}
+
+	// The slot is taken before the job is registered, so a full server
+	// leaves no pending job behind. A PUT that raced this one and
+	// registered the ID first gives it back.
+	if !acquireSolve(w, r) {
+		return
+	}
+	resp, created, err := globalJobs.createWithID(id, key)
+	if errors.Is(err, errJobStoreFull) {
+		globalSolves.release()
+		writeJobStoreFull(w, r)
+		return
+	}
+	if err != nil {
+		globalSolves.release()
+		writeError(w, r, http.StatusConflict, errCodeJobConflict, err.Error())
+		return
+	}
+	if !created {
+		globalSolves.release()
+		writeJSON(w, r, http.StatusOK, resp)
+		return
+	}
+	go runJob(context.WithoutCancel(r.Context()), id, req, callback)
+
+	w.Header().Set("Location", "/api/v1/load-optimizer/jobs/"+id)
+	writeJSON(w, r, http.StatusAccepted, resp)
+}
+
+// writeJobStoreFull turns a job away with a 503. Room is made as finished
+// jobs expire, which cleanupLoop checks once a minute.
+func writeJobStoreFull(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Retry-After", "60")
+	writeError(w, r, http.StatusServiceUnavailable, errCodeOverloaded, errJobStoreFull.Error())
+}
+
+func getJobHandler(w http.ResponseWriter, r *http.Request) {
+	resp, found := globalJobs.get(r.PathValue("id"))
+	if !found {
+		writeError(w, r, http.StatusNotFound, errCodeNotFound, "job not found")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
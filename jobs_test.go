@@ -0,0 +1,251 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJobsRespectSolveLimit(t *testing.T) {
+	saved := globalSolves
+	defer func() { globalSolves = saved }()
+	globalSolves = newSolveLimiter(1)
+	id := randomID()
+
+	body, err := json.Marshal(testRequest(testOrder("a", 1000, 1000, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	submit := func(method, path string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentTypeJSON)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		if method == http.MethodPost {
+			createJobHandler(w, r)
+		} else {
+			putJobHandler(w, r)
+		}
+		return w
+	}
+
+	// With the only slot taken, neither kind of submission may start a job
+	if !globalSolves.tryAcquire() {
+		t.Fatal("fresh limiter has no free slot")
+	}
+	for _, method := range []string{http.MethodPost, http.MethodPut} {
+		if w := submit(method, "/api/v1/load-optimizer/jobs/"+id); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s with no free slot: status %d, Retry-After %q; want 503 with Retry-After", method, w.Code, w.Header().Get("Retry-After"))
+		}
+	}
+	if _, found := globalJobs.get(id); found {
+		t.Error("a rejected PUT left a job behind")
+	}
+	globalSolves.release()
+
+	// Once free, a job takes the slot and gives it back when its solve ends
+	if w := submit(http.MethodPut, "/api/v1/load-optimizer/jobs/"+id); w.Code != http.StatusAccepted {
+		t.Fatalf("PUT with a free slot: status %d, want 202", w.Code)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !globalSolves.tryAcquire() {
+		if time.Now().After(deadline) {
+			t.Fatal("the job never gave its solve slot back")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if resp, _ := globalJobs.get(id); resp.Status != jobDone {
+		t.Errorf("job status %q after its slot was freed, want %q", resp.Status, jobDone)
+	}
+}
+
+func TestPutJobRetryAndConflict(t *testing.T) {
+	saved := globalSolves
+	savedSecret := cfg.WebhookSecret
+	defer func() { globalSolves, cfg.WebhookSecret = saved, savedSecret }()
+	globalSolves = newSolveLimiter(1)
+	cfg.WebhookSecret = "test-secret" // so callback_url is accepted
+	id := randomID()
+
+	req := testRequest(testOrder("a", 1000, 1000, 100))
+	put := func(req *OptimizeRequest, query string) *httptest.ResponseRecorder {
+		body, err := json.Marshal(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := httptest.NewRequest(http.MethodPut, "/api/v1/load-optimizer/jobs/"+id+query, bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentTypeJSON)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		putJobHandler(w, r)
+		return w
+	}
+
+	if w := put(req, ""); w.Code != http.StatusAccepted {
+		t.Fatalf("first PUT: status %d, want 202", w.Code)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for resp, _ := globalJobs.get(id); resp.Status == jobPending; resp, _ = globalJobs.get(id) {
+		if time.Now().After(deadline) {
+			t.Fatal("the job never finished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// With the server full, a retry still gets the job and a conflict
+	// still gets 409, neither a 503
+	if !globalSolves.tryAcquire() {
+		t.Fatal("the job never gave its solve slot back")
+	}
+	defer globalSolves.release()
+	if w := put(req, ""); w.Code != http.StatusOK {
+		t.Errorf("retry: status %d, want 200", w.Code)
+	}
+
+	seed := int64(7)
+	seeded := *req
+	seeded.Seed = &seed
+	tests := []struct {
+		name  string
+		req   *OptimizeRequest
+		query string
+	}{
+		{"seed", &seeded, ""},
+		{"callback_url", req, "?callback_url=https://example.com/hook"},
+	}
+	for _, tt := range tests {
+		if w := put(tt.req, tt.query); w.Code != http.StatusConflict {
+			t.Errorf("different %s: status %d, want 409", tt.name, w.Code)
+		}
+	}
+}
+
+func TestBatchEndpointsRespectSolveLimit(t *testing.T) {
+	saved := globalSolves
+	defer func() { globalSolves = saved }()
+	globalSolves = newSolveLimiter(1)
+
+	batch, err := json.Marshal(BatchRequest{Requests: []OptimizeRequest{*testRequest(testOrder("a", 1000, 1000, 100))}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+	}{
+		{"batch", batchHandler, string(batch)},
+		{"warm", warmHandler, string(batch)},
+		{"corridors", corridorHandler, corridorBody(t, testOrder("a", 1000, 1000, 100))},
+	}
+	run := func(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentTypeJSON)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	if !globalSolves.tryAcquire() {
+		t.Fatal("fresh limiter has no free slot")
+	}
+	for _, tt := range tests {
+		if w := run(tt.handler, tt.body); w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+			t.Errorf("%s with no free slot: status %d, Retry-After %q; want 503 with Retry-After", tt.name, w.Code, w.Header().Get("Retry-After"))
+		}
+	}
+	globalSolves.release()
+
+	// With the slot free each runs and gives it back
+	for _, tt := range tests {
+		if w := run(tt.handler, tt.body); w.Code != http.StatusOK {
+			t.Errorf("%s with a free slot: status %d, want 200: %s", tt.name, w.Code, w.Body)
+		}
+		if !globalSolves.tryAcquire() {
+			t.Fatalf("%s kept its solve slot", tt.name)
+		}
+		globalSolves.release()
+	}
+}
+
+func TestJobStoreLimits(t *testing.T) {
+	saved := now
+	defer func() { now = saved }()
+	start := time.Now()
+	now = func() time.Time { return start }
+
+	s := newJobStore(time.Minute, time.Hour, 2)
+	stalled, err := s.create("k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, created, err := s.createWithID("mine", "k2"); !created || err != nil {
+		t.Fatalf("createWithID: created %t, err %v", created, err)
+	}
+	// Full: new jobs are refused, but a retry still finds its job
+	if _, err := s.create("k3"); !errors.Is(err, errJobStoreFull) {
+		t.Errorf("create on a full store: err %v, want %v", err, errJobStoreFull)
+	}
+	if _, created, err := s.createWithID("other", "k3"); created || !errors.Is(err, errJobStoreFull) {
+		t.Errorf("createWithID on a full store: created %t, err %v; want %v", created, err, errJobStoreFull)
+	}
+	if resp, created, err := s.createWithID("mine", "k2"); created || err != nil || resp.JobID != "mine" {
+		t.Errorf("retry on a full store: job %q, created %t, err %v; want the existing job", resp.JobID, created, err)
+	}
+
+	// A job still pending after pendingTTL fails, and a late finish
+	// doesn't revive it
+	now = func() time.Time { return start.Add(time.Hour + time.Second) }
+	s.cleanup()
+	if resp, _ := s.get(stalled); resp.Status != jobError || resp.Error == "" {
+		t.Errorf("stalled job: status %q, error %q; want %q with a reason", resp.Status, resp.Error, jobError)
+	}
+	s.finish(stalled, &OptimizeResponse{}, "")
+	if resp, _ := s.get(stalled); resp.Status != jobError {
+		t.Errorf("stalled job after a late finish: status %q, want %q", resp.Status, jobError)
+	}
+
+	// Then it is dropped after ttl like any finished job
+	now = func() time.Time { return start.Add(time.Hour + 2*time.Minute) }
+	s.cleanup()
+	if _, found := s.get(stalled); found {
+		t.Error("failed job kept past its ttl")
+	}
+	if _, err := s.create("k4"); err != nil {
+		t.Errorf("create once room was made: %v", err)
+	}
+}
+
+func TestPutJobNonce(t *testing.T) {
+	id := randomID()
+	body, err := json.Marshal(testRequest(testOrder("a", 1000, 1000, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(nonce string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPut, "/api/v1/load-optimizer/jobs/"+id, bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentTypeJSON)
+		r.Header.Set("X-Nonce", nonce)
+		r.SetPathValue("id", id)
+		w := httptest.NewRecorder()
+		jobHandler(w, r)
+		return w
+	}
+
+	nonce := randomID()
+	if w := put(nonce); w.Code != http.StatusAccepted {
+		t.Fatalf("first PUT: status %d, want 202: %s", w.Code, w.Body)
+	}
+	// A captured PUT can't be replayed, but a retry with a fresh nonce works
+	if w := put(nonce); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), errCodeReplay) {
+		t.Errorf("replayed PUT: status %d, body %s; want 409 %s", w.Code, w.Body, errCodeReplay)
+	}
+	if w := put(randomID()); w.Code != http.StatusOK {
+		t.Errorf("retry with a fresh nonce: status %d, want 200: %s", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// solveLimiter caps how many solves run at once, synchronous or as jobs.
+// When it's full, callers are turned away immediately instead of queuing
+// behind slow solves.
+type solveLimiter struct {
+	slots    chan struct{} // nil means unlimited
+	inFlight atomic.Int64
+	rejected atomic.Int64
+}
+
+// Global limiter for /optimize-style solves and async jobs
+var globalSolves = newSolveLimiter(cfg.MaxConcurrentSolves)
+
+func newSolveLimiter(limit int) *solveLimiter {
+	l := &solveLimiter{}
+	if limit > 0 {
+		l.slots = make(chan struct{}, limit)
+	}
+	return l
+}
+
+// tryAcquire takes a slot if one is free; callers must release it when done
+func (l *solveLimiter) tryAcquire() bool {
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		default:
+			l.rejected.Add(1)
+			return false
+		}
+	}
+	l.inFlight.Add(1)
+	return true
+}
+
+func (l *solveLimiter) release() {
+	l.inFlight.Add(-1)
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// memoryBudget caps the DP table memory that queued solves hold at once.
+// Unlike solveLimiter, callers wait for room instead of being turned away.
+type memoryBudget struct {
+	mu    sync.Mutex
+	freed *sync.Cond
+	limit int64 // zero means no budget
+	used  int64
+}
+
+// Budget for corridor solves, which run many tables on the batch pool
+var corridorMemory = newMemoryBudget(cfg.CorridorMemoryBytes)
+
+func newMemoryBudget(limit int64) *memoryBudget {
+	b := &memoryBudget{limit: limit}
+	b.freed = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire blocks until n bytes fit in the budget and returns the amount
+// reserved, to pass to release. A solve larger than the whole budget
+// reserves all of it, so it runs alone rather than never.
+func (b *memoryBudget) acquire(n int64) int64 {
+	if b.limit <= 0 {
+		return 0
+	}
+	n = min(n, b.limit)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.used+n > b.limit {
+		b.freed.Wait()
+	}
+	b.used += n
+	return n
+}
+
+func (b *memoryBudget) release(n int64) {
+	if n == 0 {
+		return
+	}
+	b.mu.Lock()
+	b.used -= n
+	b.mu.Unlock()
+	b.freed.Broadcast()
+}
+
+// maxRetryAfterSeconds bounds the jittered Retry-After on overloaded solves
+const maxRetryAfterSeconds = 3
+
+// acquireSolve takes a solve slot, or writes a 503 with Retry-After and
+// reports false when every slot is busy. When a slot frees up can't be
+// known, so Retry-After is jittered between 1 and maxRetryAfterSeconds to
+// keep turned-away clients from all retrying at once.
+func acquireSolve(w http.ResponseWriter, r *http.Request) bool {
+	if globalSolves.tryAcquire() {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(1+rand.IntN(maxRetryAfterSeconds)))
+	writeError(w, r, http.StatusServiceUnavailable, errCodeOverloaded, "too many solves in progress, retry shortly")
+	return false
+}
+
+// writeSolveError reports a solve that was refused, either because the
+// forced solver can't handle it or for capacity reasons
+func writeSolveError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errSolverRefused) {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+		return
+	}
+	writeError(w, r, http.StatusServiceUnavailable, errCodeSubsetLimit, err.Error())
+}
+
+// metricsHandler reports live solver load
+func metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, map[string]interface{}{
+		"solves_in_flight":      globalSolves.inFlight.Load(),
+		"solves_rejected":       globalSolves.rejected.Load(),
+		"max_concurrent_solves": cfg.MaxConcurrentSolves,
+	})
+}
@@ -0,0 +1,39 @@
+package main
+
+// localSearchRadius is how many orders a what-if re-solve may add or remove
+// relative to the pinned plan. Three covers adding a new order and swapping
+// out up to two others to make room.
+const localSearchRadius = 3
+
+// FindLocal returns the best valid load within localSearchRadius additions
+// or removals of start, preferring start itself on ties so an accepted plan
+// only changes when there is something to gain. It checks every candidate
+// with isValidSubset, so it doesn't need the DP tables. The result is only
+// guaranteed optimal when the radius covers every order.
+func (o *Optimizer) FindLocal(start int) int {
+	bestMask := 0
+	bestScore := int64(-1)
+
+	var visit func(mask, from, flips int)
+	visit = func(mask, from, flips int) {
+		if o.fits(mask) && o.isValidSubset(mask) && o.complete(mask) {
+			if score := o.score(mask); score > bestScore {
+				bestScore = score
+				bestMask = mask
+			}
+		}
+		if flips == localSearchRadius {
+			return
+		}
+		for i := from; i < o.n; i++ {
+			visit(mask^(1<<i), i+1, flips+1)
+		}
+	}
+	visit(start, 0, 0)
+
+	// Nothing near the pinned plan is valid; an empty load always is
+	if bestScore < 0 {
+		return 0
+	}
+	return bestMask
+}
@@ -1,56 +1,384 @@
 package main
 
 import (
+	"cmp"
+	"compress/gzip"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"math/bits"
+	"mime"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/net/netutil"
 )
 
 // Request/Response models
 type Truck struct {
-	ID            string `json:"id"`
-	MaxWeightLbs  int64  `json:"max_weight_lbs"`
-	MaxVolumeCuft int64  `json:"max_volume_cuft"`
+	ID            string   `json:"id"`
+	MaxWeightLbs  Quantity `json:"max_weight_lbs"`
+	MaxVolumeCuft Quantity `json:"max_volume_cuft"`
+	// Optional days from departure to arrival; co-loaded orders share one trip
+	TransitDays   int64  `json:"transit_days,omitempty"`
+	// Optional soft overweight: loads may exceed max_weight_lbs by up to this
+	// percentage, paying the penalty per pound over
+	WeightOveragePercent     float64 `json:"weight_overage_percent,omitempty"`
+	OveragePenaltyCentsPerLb int64   `json:"overage_penalty_cents_per_lb,omitempty"`
+	// Optional; false for trailers that aren't hazmat-certified. Omitted
+	// means allowed.
+	HazmatAllowed *bool `json:"hazmat_allowed,omitempty"`
+	// Optional emissions rate, for estimated_co2_grams
+	CO2GramsPerMile int64 `json:"co2_grams_per_mile,omitempty"`
+	// Optional cost of dispatching the truck at all, whatever it carries
+	FixedCostCents int64 `json:"fixed_cost_cents,omitempty"`
+	// Optional cargo already on board, taking up part of the capacity
+	PreloadedWeightLbs  Quantity `json:"preloaded_weight_lbs,omitempty"`
+	PreloadedVolumeCuft Quantity `json:"preloaded_volume_cuft,omitempty"`
+	// Optional weight limit per axle group, front to back; each order rests
+	// on the one at its axle_position
+	AxleCapacities []Quantity `json:"axle_capacities,omitempty"`
+}
+
+// allowsHazmat reports whether the truck may carry hazmat orders at all
+func (t Truck) allowsHazmat() bool {
+	return t.HazmatAllowed == nil || *t.HazmatAllowed
+}
+
+// weightCap returns the most a load may weigh, including any allowed overage.
+// The overage is a share of the full rating; preloaded cargo counts against it.
+func (t Truck) weightCap() Quantity {
+	return t.MaxWeightLbs + Quantity(float64(t.MaxWeightLbs)*t.WeightOveragePercent/100) - t.PreloadedWeightLbs
+}
+
+// freeWeightLbs is the weight the truck can take before any overage, after
+// its preloaded cargo
+func (t Truck) freeWeightLbs() Quantity {
+	return t.MaxWeightLbs - t.PreloadedWeightLbs
+}
+
+// freeVolumeCuft is the volume left after the truck's preloaded cargo
+func (t Truck) freeVolumeCuft() Quantity {
+	return t.MaxVolumeCuft - t.PreloadedVolumeCuft
 }
 
 type Order struct {
-	ID            string `json:"id"`
-	PayoutCents   int64  `json:"payout_cents"`
-	WeightLbs     int64  `json:"weight_lbs"`
-	VolumeCuft    int64  `json:"volume_cuft"`
-	Origin        string `json:"origin"`
-	Destination   string `json:"destination"`
-	PickupDate    string `json:"pickup_date"`
-	DeliveryDate  string `json:"delivery_date"`
-	IsHazmat      bool   `json:"is_hazmat"`
+	ID           string   `json:"id"`
+	PayoutCents  int64    `json:"payout_cents"`
+	WeightLbs    Quantity `json:"weight_lbs"`
+	VolumeCuft   Quantity `json:"volume_cuft"`
+	Origin       string   `json:"origin"`
+	Destination  string   `json:"destination"`
+	PickupDate   string   `json:"pickup_date"`
+	DeliveryDate string   `json:"delivery_date"`
+	IsHazmat     bool     `json:"is_hazmat"`
+	// Optional trip length, for estimated_co2_grams
+	DistanceMiles int64 `json:"distance_miles,omitempty"`
+	// Optional cost of carrying the order, for the maximin_margin objective
+	CostCents int64 `json:"cost_cents,omitempty"`
+	// Optional product category, for max_category_percent
+	Category string `json:"category,omitempty"`
+	// Index into truck.axle_capacities of the axle group the order rests on
+	AxlePosition int `json:"axle_position,omitempty"`
+	// Optional ISO 4217 code of payout_cents and cost_cents; DEFAULT_CURRENCY
+	// when omitted
+	Currency string `json:"currency,omitempty"`
 }
 
 type OptimizeRequest struct {
 	Truck   Truck   `json:"truck"`
 	Orders  []Order `json:"orders"`
+	// Optional multi-stop pickups: co-load orders from up to MaxOrigins
+	// distinct origins (0 = no limit) when every pickup precedes every delivery
+	AllowMultiOrigin bool `json:"allow_multi_origin,omitempty"`
+	MaxOrigins       int  `json:"max_origins,omitempty"`
+	// Optional multi-drop deliveries, the same way for destinations
+	AllowMultiDestination bool `json:"allow_multi_destination,omitempty"`
+	MaxDestinations       int  `json:"max_destinations,omitempty"`
+	// Optional cap on orders dropped at any one destination (0 = no limit)
+	MaxOrdersPerDestination int `json:"max_orders_per_destination,omitempty"`
+	// Optional lists of order IDs that must ship together or not at all
+	OrderGroups [][]string `json:"order_groups,omitempty"`
+	// Optional [idA, idB] pairs that must never share a load, e.g.
+	// competitors' freight
+	IncompatiblePairs [][]string `json:"incompatible_pairs,omitempty"`
+	// Optional extra payout earned when two orders ship together, e.g. a
+	// backhaul pairing. Unlike the preference bonus it is real money, so it
+	// is part of total_payout_cents.
+	CoLoadBonuses []CoLoadBonus `json:"co_load_bonuses,omitempty"`
+	// Optional single-dimension loads: the ignored capacity never limits a
+	// load, needn't be set on the truck, and reports 0 utilization
+	IgnoreWeight bool `json:"ignore_weight,omitempty"`
+	IgnoreVolume bool `json:"ignore_volume,omitempty"`
+	// Optional soft preference: each preferred order in the load adds
+	// PreferenceBonusCents to its score, but not to the reported payout
+	PreferredOrderIDs    []string `json:"preferred_order_ids,omitempty"`
+	PreferenceBonusCents int64    `json:"preference_bonus_cents,omitempty"`
+	// Optional previously accepted plan. The solver then only looks at loads
+	// a few orders away from it, for quick what-if edits.
+	PinnedOrderIDs []string `json:"pinned_order_ids,omitempty"`
+	// Optional hand-built plan. If it's feasible and pays more than the
+	// solver's answer, it is returned instead.
+	BaselineOrderIDs []string `json:"baseline_order_ids,omitempty"`
+	// Optional floor on total_payout_cents; a best load paying less isn't
+	// worth dispatching and an empty plan is returned instead
+	MinTotalPayoutCents int64 `json:"min_total_payout_cents,omitempty"`
+	// Optional cap on any one orders[].category's share of the load's
+	// weight, e.g. for insurance; 0 means no limit
+	MaxCategoryPercent float64 `json:"max_category_percent,omitempty"`
+	// Optional tie-break for the exact solver: among loads with the same
+	// score, prefer the one with the earliest delivery_date
+	TieBreakByDelivery bool `json:"tie_break_by_delivery,omitempty"`
+	// Optional sort order for the greedy fallback (see greedySort* constants)
+	GreedySort string `json:"greedy_sort,omitempty"`
+	// Optional seed for breaking greedy ties in a shuffled but reproducible
+	// order; without it ties go to the earlier order in the request
+	Seed *int64 `json:"seed,omitempty"`
+	// Optional solver to force instead of choosing automatically (see
+	// solver* constants), for testing and benchmarking each code path
+	Solver string `json:"solver,omitempty"`
+	// Optional objective for the exact solver instead of the highest total
+	// (see objective* constants)
+	Objective string `json:"objective,omitempty"`
+	// With the target_utilization objective, the weight utilization to get
+	// closest to, as a percentage of the free weight capacity
+	TargetWeightPercent float64 `json:"target_weight_percent,omitempty"`
+	// Optional ID of the pricing snapshot the payouts came from. It doesn't
+	// affect the solve but is echoed back and part of the cache key, so audits
+	// can tie a decision to the prices it was based on.
+	PricingSnapshotID string `json:"pricing_snapshot_id,omitempty"`
+	// Optional units for all weights/volumes in the request: "lbs"/"kg", "cuft"/"m3"
+	WeightUnit string `json:"weight_unit,omitempty"`
+	VolumeUnit string `json:"volume_unit,omitempty"`
+
+	// Per-request order limit from X-Max-Orders, replacing the order cap and
+	// MAX_SUBSETS for this request only; 0 keeps the global limits
+	maxOrders int
+}
+
+// CoLoadBonus pays BonusCents on top of both payouts when OrderA and OrderB
+// are in the same load
+type CoLoadBonus struct {
+	OrderA     string `json:"order_a"`
+	OrderB     string `json:"order_b"`
+	BonusCents int64  `json:"bonus_cents"`
+}
+
+// LineItem is one selected order and its share of the load's totals
+type LineItem struct {
+	OrderID       string   `json:"order_id" xml:"order_id"`
+	PayoutCents   int64    `json:"payout_cents" xml:"payout_cents"`
+	WeightLbs     Quantity `json:"weight_lbs" xml:"weight_lbs"`
+	VolumeCuft    Quantity `json:"volume_cuft" xml:"volume_cuft"`
+	PayoutPercent float64  `json:"payout_percent" xml:"payout_percent"`
+	WeightPercent float64  `json:"weight_percent" xml:"weight_percent"`
+	VolumePercent float64  `json:"volume_percent" xml:"volume_percent"`
+}
+
+// Line item orders for ?sort_by, largest first. Without it items keep the
+// request's order.
+const (
+	lineItemSortPayout = "payout"
+	lineItemSortWeight = "weight"
+	lineItemSortVolume = "volume"
+)
+
+// lineItems itemizes the selected orders from the request as the client sent
+// it, so values are in the client's units. A sortBy key orders them by that
+// value descending, ties in request order.
+func lineItems(resp *OptimizeResponse, req *OptimizeRequest, sortBy string) []LineItem {
+	items := make([]LineItem, 0, len(resp.selectedIndices))
+	for _, i := range resp.selectedIndices {
+		o := req.Orders[i]
+		items = append(items, LineItem{
+			OrderID:       o.ID,
+			PayoutCents:   o.PayoutCents,
+			WeightLbs:     o.WeightLbs,
+			VolumeCuft:    o.VolumeCuft,
+			PayoutPercent: percentOf(o.PayoutCents, resp.TotalPayoutCents),
+			WeightPercent: percentOf(int64(o.WeightLbs), int64(resp.TotalWeightLbs)),
+			VolumePercent: percentOf(int64(o.VolumeCuft), int64(resp.TotalVolumeCuft)),
+		})
+	}
+
+	var key func(LineItem) int64
+	switch sortBy {
+	case lineItemSortPayout:
+		key = func(item LineItem) int64 { return item.PayoutCents }
+	case lineItemSortWeight:
+		key = func(item LineItem) int64 { return int64(item.WeightLbs) }
+	case lineItemSortVolume:
+		key = func(item LineItem) int64 { return int64(item.VolumeCuft) }
+	default:
+		return items
+	}
+	slices.SortStableFunc(items, func(a, b LineItem) int {
+		return cmp.Compare(key(b), key(a))
+	})
+	return items
+}
+
+// percentOf returns part as a percentage of total, or 0 when total is 0
+func percentOf(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return roundTo2Decimals(float64(part) / float64(total) * 100)
+}
+
+// Diagnostics reports how much work the exact solver did
+type Diagnostics struct {
+	// Non-empty subsets of the orders, 2^n - 1
+	SubsetsTotal int64 `json:"subsets_total" xml:"subsets_total"`
+	// Subsets the DP evaluated; the rest were pruned as supersets of an
+	// invalid subset without being visited
+	SubsetsVisited        int64   `json:"subsets_visited" xml:"subsets_visited"`
+	SubsetsSkippedPercent float64 `json:"subsets_skipped_percent" xml:"subsets_skipped_percent"`
+	// Solver deadline minus the time /optimize took to answer, negative if
+	// it ran over; null when the request had no deadline
+	SolveDeadlineRemainingMs *int64 `json:"solve_deadline_remaining_ms" xml:"solve_deadline_remaining_ms"`
 }
 
 type OptimizeResponse struct {
-	TruckID                 string   `json:"truck_id"`
-	SelectedOrderIDs        []string `json:"selected_order_ids"`
-	TotalPayoutCents        int64    `json:"total_payout_cents"`
-	TotalWeightLbs          int64    `json:"total_weight_lbs"`
-	TotalVolumeCuft         int64    `json:"total_volume_cuft"`
-	UtilizationWeightPercent float64 `json:"utilization_weight_percent"`
-	UtilizationVolumePercent float64 `json:"utilization_volume_percent"`
+	XMLName xml.Name `json:"-" xml:"optimize_response"`
+	TruckID                 string   `json:"truck_id" xml:"truck_id"`
+	// Echoed from the request
+	PricingSnapshotID       string   `json:"pricing_snapshot_id,omitempty" xml:"pricing_snapshot_id,omitempty"`
+	// Currency of every amount in the response, from the orders
+	Currency string `json:"currency,omitempty" xml:"currency,omitempty"`
+	SelectedOrderIDs        []string `json:"selected_order_ids" xml:"selected_order_ids>id"`
+	// 0-based positions of the selected orders in the request, only with
+	// ?include_indices=true
+	SelectedOrderIndices []int `json:"selected_order_indices,omitempty" xml:"selected_order_indices>index"`
+	// Per-order breakdown of the selected load, only with ?itemize=true
+	LineItems []LineItem `json:"line_items,omitempty" xml:"line_items>line_item,omitempty"`
+	// Always filled by BuildResponse; copied out on request so cached
+	// responses can serve both forms
+	selectedIndices []int
+	// Orders too heavy or bulky for this truck even on their own, including
+	// orders over their axle group's limit
+	InfeasibleOrderIDs      []string `json:"infeasible_order_ids" xml:"infeasible_order_ids>id"`
+	// Distinct pickup origins of the selected orders, for multi-origin requests
+	Origins                 []string `json:"origins,omitempty" xml:"origins>origin,omitempty"`
+	// Distinct drop destinations of the selected orders, for multi-destination requests
+	Destinations            []string `json:"destinations,omitempty" xml:"destinations>destination,omitempty"`
+	TotalPayoutCents        int64    `json:"total_payout_cents" xml:"total_payout_cents"`
+	// Part of total_payout_cents earned from co_load_bonuses
+	CoLoadBonusCents int64 `json:"co_load_bonus_cents,omitempty" xml:"co_load_bonus_cents,omitempty"`
+	// Total payout divided by the number of selected orders, rounded down; 0 when empty
+	AvgPayoutPerOrderCents   int64    `json:"avg_payout_per_order_cents" xml:"avg_payout_per_order_cents"`
+	TotalWeightLbs           Quantity `json:"total_weight_lbs" xml:"total_weight_lbs"`
+	TotalVolumeCuft          Quantity `json:"total_volume_cuft" xml:"total_volume_cuft"`
+	RemainingWeightLbs       Quantity `json:"remaining_weight_lbs" xml:"remaining_weight_lbs"`
+	RemainingVolumeCuft      Quantity `json:"remaining_volume_cuft" xml:"remaining_volume_cuft"`
+	UtilizationWeightPercent float64  `json:"utilization_weight_percent" xml:"utilization_weight_percent"`
+	UtilizationVolumePercent float64  `json:"utilization_volume_percent" xml:"utilization_volume_percent"`
+	Optimal                  bool     `json:"optimal" xml:"optimal"`
+	// Weight over max_weight_lbs and its penalty, when the truck allows overage.
+	// The penalty is not deducted from total_payout_cents.
+	OverageLbs          Quantity `json:"overage_lbs,omitempty" xml:"overage_lbs,omitempty"`
+	OveragePenaltyCents int64    `json:"overage_penalty_cents,omitempty" xml:"overage_penalty_cents,omitempty"`
+	// The truck's fixed_cost_cents when the load is dispatched, also not
+	// deducted from total_payout_cents. NotWorthDispatching is set when the
+	// best load didn't earn it back, so the plan was left empty.
+	FixedCostCents      int64 `json:"fixed_cost_cents,omitempty" xml:"fixed_cost_cents,omitempty"`
+	NotWorthDispatching bool  `json:"not_worth_dispatching,omitempty" xml:"not_worth_dispatching,omitempty"`
+	// Set when the best load paid less than min_total_payout_cents, so the
+	// plan was left empty
+	BelowMinPayout bool `json:"below_min_payout,omitempty" xml:"below_min_payout,omitempty"`
+	// SHA-256 of the sorted selected order IDs and totals, to spot an
+	// unchanged plan without comparing arrays
+	SolutionHash string `json:"solution_hash" xml:"solution_hash"`
+	// Which capacity kept more orders off the truck: "weight", "volume" or "none"
+	BindingConstraint string `json:"binding_constraint" xml:"binding_constraint"`
+	// Orders left off the load, grouped by why; absent when all were selected
+	RejectedOrders *RejectedOrders `json:"rejected_orders,omitempty" xml:"rejected_orders,omitempty"`
+	// The best paying order left off that no rule keeps out, as a hint of
+	// what to make room for next; absent when there is none
+	BestAddition *BestAddition `json:"best_addition,omitempty" xml:"best_addition,omitempty"`
+	// Set when baseline_order_ids paid more than the solver's answer and was
+	// returned in its place
+	UsedBaseline bool `json:"used_baseline,omitempty" xml:"used_baseline,omitempty"`
+	// With the maximin_margin objective, the worst payout_cents - cost_cents
+	// among the selected orders; absent for an empty load
+	MinMarginCents *int64 `json:"min_margin_cents,omitempty" xml:"min_margin_cents,omitempty"`
+	// Likely misconfigurations that don't make the request invalid
+	Warnings []string `json:"warnings,omitempty" xml:"warnings>warning,omitempty"`
+	// Emissions proxy: the longest selected distance_miles (co-loaded orders
+	// share one corridor) times the truck's co2_grams_per_mile. Absent
+	// unless both are given.
+	EstimatedCO2Grams *int64 `json:"estimated_co2_grams,omitempty" xml:"estimated_co2_grams,omitempty"`
+	// For heuristic answers: payout as a percentage of a proven upper bound on
+	// the optimum, so the answer is at least this close to optimal
+	OptimalityBoundPercent *float64 `json:"optimality_bound_percent,omitempty" xml:"optimality_bound_percent,omitempty"`
+	// How many cents of score the chosen load is ahead of the next best load
+	// with a different score. Only for exact answers; absent when every other
+	// load ties it or none exists.
+	MarginOverRunnerUpCents *int64 `json:"margin_over_runner_up_cents,omitempty" xml:"margin_over_runner_up_cents,omitempty"`
+	// Loads that trade payout against capacity used, only with ?pareto=true
+	ParetoFrontier []ParetoPoint `json:"pareto_frontier,omitempty" xml:"pareto_frontier>point,omitempty"`
+	// Orders selected in every one of the best plans, only with
+	// ?stable_top_k=K. Absent when those plans share no order.
+	StableOrderIDs []string `json:"stable_order_ids,omitempty" xml:"stable_order_ids>id,omitempty"`
+	// How the exact solver reached its answer, only with ?trace=true
+	Trace *SolveTrace `json:"trace,omitempty" xml:"trace,omitempty"`
+	// Solver statistics, for tuning and troubleshooting
+	Diagnostics *Diagnostics `json:"diagnostics,omitempty" xml:"diagnostics,omitempty"`
+	// Weight on each of truck.axle_capacities' axle groups, in request order
+	AxleLoads []AxleLoad `json:"axle_loads,omitempty" xml:"axle_loads>axle_load,omitempty"`
+	// Set when the request used non-default units; totals are in these units
+	WeightUnit string `json:"weight_unit,omitempty" xml:"weight_unit,omitempty"`
+	VolumeUnit string `json:"volume_unit,omitempty" xml:"volume_unit,omitempty"`
 }
 
 type ErrorResponse struct {
-	Error   string `json:"error"`
-	Message string `json:"message"`
+	XMLName xml.Name `json:"-" xml:"error_response"`
+	Error     string `json:"error" xml:"error"`
+	Message   string `json:"message" xml:"message"`
+	Code      string `json:"code" xml:"code"`
+	RequestID string `json:"request_id,omitempty" xml:"request_id,omitempty"`
 }
 
+// Machine-readable ErrorResponse codes
+const (
+	errCodeInvalidJSON     = "invalid_json"
+	errCodeInvalidBody     = "invalid_body"
+	errCodeInvalidHeader   = "invalid_header"
+	errCodeInvalidQuery    = "invalid_query"
+	errCodeValidation      = "validation_failed"
+	errCodePayloadTooLarge = "payload_too_large"
+	errCodeNotFound        = "not_found"
+	errCodeUnauthorized    = "unauthorized"
+	errCodeForbidden       = "forbidden"
+	errCodeMethod          = "method_not_allowed"
+	errCodeOverloaded      = "overloaded"
+	errCodeRateLimited     = "rate_limited"
+	errCodeSubsetLimit     = "subset_limit_exceeded"
+	errCodeReplay          = "replayed_nonce"
+	errCodeMediaType       = "unsupported_media_type"
+	errCodeJobConflict     = "job_conflict"
+)
+
 // Cache entry
 type cacheEntry struct {
 	response   *OptimizeResponse
@@ -69,6 +397,16 @@ type responseCache struct {
 // Global cache instance
 var globalCache = newResponseCache(1000) // Cache up to 1000 responses
 
+// cacheTTL is how long optimal results stay cached
+const cacheTTL = 5 * time.Minute
+
+// maxOrders is the largest request the exact solver accepts (2^22 subsets)
+const maxOrders = 22
+
+// maxOverrideOrders is the most X-Max-Orders may allow: 2^26 subsets, about
+// 2.5 GB of DP tables for a single request
+const maxOverrideOrders = 26
+
 func newResponseCache(maxSize int) *responseCache {
 	return &responseCache{
 		store:   make(map[string]*cacheEntry),
@@ -111,10 +449,105 @@ func (c *responseCache) put(key string, response *OptimizeResponse, ttl time.Dur
 	c.keys = append(c.keys, key)
 }
 
-// cacheKey generates a hash key from the request
+// clear empties the cache and returns the number of entries removed
+func (c *responseCache) clear() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := len(c.store)
+	c.store = make(map[string]*cacheEntry)
+	c.keys = make([]string, 0, c.maxSize)
+	return n
+}
+
+// remove drops a single entry and reports whether it existed
+func (c *responseCache) remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.store[key]; !exists {
+		return false
+	}
+	delete(c.store, key)
+	kept := c.keys[:0]
+	for _, k := range c.keys {
+		if k != key {
+			kept = append(kept, k)
+		}
+	}
+	c.keys = kept
+	return true
+}
+
+// cacheKeyFields is the part of an OptimizeRequest that can change a cached
+// response. Anything else, such as greedy_sort or seed (only cached optimal
+// answers are served, and those don't depend on the greedy order), is left
+// out so requests differing only there share an entry. Keep this in sync
+// when adding request fields; new fields are ignored by the cache until listed.
+type cacheKeyFields struct {
+	Truck                 Truck         `json:"truck"`
+	Orders                []Order       `json:"orders"`
+	AllowMultiOrigin      bool          `json:"allow_multi_origin"`
+	MaxOrigins            int           `json:"max_origins"`
+	AllowMultiDestination bool          `json:"allow_multi_destination"`
+	MaxDestinations       int           `json:"max_destinations"`
+	MaxOrdersPerDest      int           `json:"max_orders_per_destination"`
+	OrderGroups           [][]string    `json:"order_groups"`
+	IncompatiblePairs     [][]string    `json:"incompatible_pairs"`
+	CoLoadBonuses         []CoLoadBonus `json:"co_load_bonuses"`
+	IgnoreWeight          bool          `json:"ignore_weight"`
+	IgnoreVolume          bool          `json:"ignore_volume"`
+	PreferredOrderIDs     []string      `json:"preferred_order_ids"`
+	PreferenceBonusCents  int64         `json:"preference_bonus_cents"`
+	PinnedOrderIDs        []string      `json:"pinned_order_ids"`
+	BaselineOrderIDs      []string      `json:"baseline_order_ids"`
+	MinTotalPayoutCents   int64         `json:"min_total_payout_cents"`
+	TieBreakByDelivery    bool          `json:"tie_break_by_delivery"`
+	MaxCategoryPercent    float64       `json:"max_category_percent"`
+	// A forced greedy solve must not be answered from an exact entry
+	Solver              string  `json:"solver"`
+	Objective           string  `json:"objective"`
+	TargetWeightPercent float64 `json:"target_weight_percent"`
+	// Echoed in the response, so it must separate entries
+	PricingSnapshotID string `json:"pricing_snapshot_id"`
+	WeightUnit        string `json:"weight_unit"`
+	VolumeUnit        string `json:"volume_unit"`
+	// X-Max-Orders decides between exact and refused, so an admin's
+	// answer mustn't serve other callers
+	MaxOrders int `json:"max_orders"`
+}
+
+// cacheKey generates a hash key from the solver-relevant request fields
 func cacheKey(req *OptimizeRequest) (string, error) {
 	// Create a deterministic representation of the request
-	data, err := json.Marshal(req)
+	data, err := json.Marshal(cacheKeyFields{
+		Truck:                 req.Truck,
+		Orders:                req.Orders,
+		AllowMultiOrigin:      req.AllowMultiOrigin,
+		MaxOrigins:            req.MaxOrigins,
+		AllowMultiDestination: req.AllowMultiDestination,
+		MaxDestinations:       req.MaxDestinations,
+		MaxOrdersPerDest:      req.MaxOrdersPerDestination,
+		OrderGroups:           req.OrderGroups,
+		IncompatiblePairs:     req.IncompatiblePairs,
+		CoLoadBonuses:         req.CoLoadBonuses,
+		IgnoreWeight:          req.IgnoreWeight,
+		IgnoreVolume:          req.IgnoreVolume,
+		PreferredOrderIDs:     req.PreferredOrderIDs,
+		PreferenceBonusCents:  req.PreferenceBonusCents,
+		PinnedOrderIDs:        req.PinnedOrderIDs,
+		BaselineOrderIDs:      req.BaselineOrderIDs,
+		MinTotalPayoutCents:   req.MinTotalPayoutCents,
+		TieBreakByDelivery:    req.TieBreakByDelivery,
+		MaxCategoryPercent:    req.MaxCategoryPercent,
+		Solver:                req.Solver,
+		Objective:             req.Objective,
+		TargetWeightPercent:   req.TargetWeightPercent,
+		PricingSnapshotID:     req.PricingSnapshotID,
+		WeightUnit:            req.WeightUnit,
+		VolumeUnit:            req.VolumeUnit,
+		MaxOrders:             req.maxOrders,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -129,327 +562,1915 @@ type Optimizer struct {
 	n        int
 	maxMask  int
 	// Pre-computed totals for each subset
-	weight   []int64
-	volume   []int64
+	weight   []Quantity
+	volume   []Quantity
 	payout   []int64
 	valid    []bool
+	// Compatibility state carried through the same DP
+	hazmat   []uint8 // hazmatFlag/nonHazmatFlag bits present in the subset
+	origins  []uint32 // bitset of origin IDs in a valid subset
+	dests    []uint32 // bitset of destination IDs in a valid subset
+	// Most distinct origins/destinations a subset may have (1 unless
+	// multi-origin/multi-destination is on)
+	maxOrigins      int
+	maxDestinations int
+	// Most orders a subset may drop at one destination (0 = no limit), and
+	// per order, the bitmask of orders sharing its destination
+	maxPerDest int
+	sameDest   []int
+	// Cap on a category's share of the load's weight, as a percentage, and
+	// the orders in each category; nil masks mean no cap
+	maxCategoryPercent float64
+	categoryMasks      []int
+	// Per axle group, the orders resting on it; nil when the truck has no
+	// axle_capacities
+	axleMasks []int
+	// All-or-nothing order groups as bitmasks
+	groups   []int
+	// Per order, a bitmask of the orders it may not share a load with;
+	// nil when the request has no incompatible pairs
+	conflicts []int
+	// Hard weight limit, max_weight_lbs plus any allowed overage, and hard
+	// volume limit; an ignored dimension is unlimited
+	weightCap    Quantity
+	volumeCap    Quantity
+	ignoreWeight bool
+	ignoreVolume bool
+	// Co-load bonuses as a bitmask of both orders, with the bonus for each
+	coLoadPairs []int
+	coLoadCents []int64
+	// Preferred orders as a bitmask, and the score bonus for each one
+	preferred       int
+	preferenceBonus int64
+	// Sort key for FindGreedy; empty means the higher of the weight and
+	// volume densities
+	greedySort string
+	// FindOptimal's objective; empty means the highest total score
+	objective string
+	// Weight the target_utilization objective aims for
+	targetWeight Quantity
+	// Break FindOptimal's score ties by the earliest delivery date
+	tieBreakByDelivery bool
+	// Optional seed for FindGreedy's tie-breaking; nil keeps request order
+	seed *int64
+	// Shared trip window, only allocated when the truck has transit_days
+	// or multi-stop loads are allowed
+	latestPickup     []int32 // day number the truck can leave at the earliest
+	earliestDelivery []int32 // day number the truck must arrive by
+	// Per-order inputs to the compatibility checks
+	originIDs, destIDs       []int32
+	pickupDays, deliveryDays []int32
+	// Subsets the DP evaluated; the rest were pruned unvisited
+	visited int
+	// Best score FindOptimal saw below the winner's, if any load scored less
+	runnerUpScore int64
+	hasRunnerUp   bool
+	// Optional solve deadline; zero means no limit
+	deadline time.Time
+	timedOut bool
 }
 
 func main() {
+	if cfg.SelfTest {
+		runSelfTest()
+	}
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		log.Fatalf("tracing: %v", err)
+	}
+
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/healthz", healthHandler)
-	mux.HandleFunc("/api/v1/load-optimizer/optimize", optimizeHandler)
+	mux.HandleFunc("/api/v1/load-optimizer/optimize", negotiateXML(rateLimited(rejectReplays(optimizeHandler))))
+	mux.HandleFunc("/api/v1/load-optimizer/best-truck", rateLimited(rejectReplays(bestTruckHandler)))
+	mux.HandleFunc("/api/v1/load-optimizer/batch", rateLimited(rejectReplays(batchHandler)))
+	mux.HandleFunc("/api/v1/load-optimizer/batch/validate", batchValidateHandler)
+	mux.HandleFunc("/api/v1/load-optimizer/compare", rateLimited(rejectReplays(compareHandler)))
+	mux.HandleFunc("/api/v1/load-optimizer/compare-pricing", rateLimited(rejectReplays(comparePricingHandler)))
+	mux.HandleFunc("/api/v1/load-optimizer/corridors", rateLimited(rejectReplays(corridorHandler)))
+	mux.HandleFunc("/api/v1/load-optimizer/pack", rateLimited(rejectReplays(packHandler)))
+	mux.HandleFunc("/api/v1/load-optimizer/simulate-capacity", rateLimited(rejectReplays(simulateCapacityHandler)))
+	mux.HandleFunc("/api/v1/load-optimizer/estimate", estimateHandler)
+	mux.HandleFunc("/api/v1/load-optimizer/capabilities", capabilitiesHandler)
+	mux.HandleFunc("/api/v1/load-optimizer/jobs", rateLimited(rejectReplays(createJobHandler)))
+	mux.HandleFunc("/api/v1/load-optimizer/jobs/{id}", jobHandler)
+	mux.HandleFunc("/api/v1/load-optimizer/cache", requireAdmin(clearCacheHandler))
+	mux.HandleFunc("/api/v1/load-optimizer/cache/{key}", requireAdmin(clearCacheHandler))
+	mux.HandleFunc("/api/v1/load-optimizer/warm", requireAdmin(warmHandler))
+	mux.HandleFunc("/config", requireAdmin(configHandler))
+	mux.HandleFunc("/metrics", requireAdmin(metricsHandler))
+	if cfg.DebugEndpoints {
+		mux.HandleFunc("/debug/dp", debugDPHandler)
+	}
+
+	go globalJobs.cleanupLoop(time.Minute)
 
 	server := &http.Server{
 		Addr:         ":8080",
-		Handler:      mux,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 5 * time.Second,
-		IdleTimeout:  10 * time.Second,
+		Handler:      withRequestID(withTraceContext(mux)),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		log.Fatal(err)
 	}
+	// Past the cap, new connections wait in the kernel's accept backlog
+	// instead of each taking a goroutine and buffers
+	if cfg.MaxConnections > 0 {
+		listener = netutil.LimitListener(listener, cfg.MaxConnections)
+	}
+
+	log.Printf("Solver thresholds: exact up to %d orders and %d subsets, meet-in-the-middle up to %d orders, greedy above", cfg.ExactMaxOrders, cfg.MaxSubsets, cfg.MimMaxOrders)
+	// SIGINT or SIGTERM stops accepting connections, lets requests in
+	// flight finish within WRITE_TIMEOUT, abandons callback retries, and
+	// flushes their spans
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		<-ctx.Done()
+		log.Println("Shutting down")
+		stopWebhooks()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.WriteTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			log.Printf("tracing shutdown: %v", err)
+		}
+	}()
 
 	log.Println("Starting server on :8080")
-	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
+	<-drained
 }
 
 func healthHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
+	// The deep check runs a tiny solve with a known answer, catching a
+	// broken solver that a liveness probe can't see
+	if r.URL.Query().Get("deep") == "true" {
+		if err := checkSolver(); err != nil {
+			writeJSON(w, r, http.StatusServiceUnavailable, map[string]string{"status": "unhealthy", "error": err.Error()})
+			return
+		}
+	}
+	writeJSON(w, r, http.StatusOK, map[string]string{"status": "healthy"})
 }
 
 func optimizeHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
-	// Check content length (max 1MB)
-	if r.ContentLength > 1<<20 {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusRequestEntityTooLarge)
-		json.NewEncoder(w).Encode(ErrorResponse{Error: "payload too large", Message: "payload too large"})
+	// A yes/no question about the full load needs no solve, so it skips
+	// the solve limits entirely, the order cap included
+	if r.URL.Query().Get("feasibility") == "true" {
+		req, ok := decodeRequestWith(w, r, validateRequestFields)
+		if ok {
+			writeJSON(w, r, http.StatusOK, feasibility(toInternalUnits(req)))
+		}
 		return
 	}
 
-	var req OptimizeRequest
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-	if err := decoder.Decode(&req); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		msg := "invalid JSON: " + err.Error()
-		json.NewEncoder(w).Encode(ErrorResponse{Error: msg, Message: msg})
-		return
+	// Per-request override of the order cap and MAX_SUBSETS, for trusted
+	// callers with the headroom for bigger solves. It can raise the limits,
+	// so only the admin key may set it. Parsed before the body so the order
+	// cap it sets applies in validation.
+	var maxOrdersOverride int
+	if h := r.Header.Get("X-Max-Orders"); h != "" {
+		if !checkAdmin(w, r) {
+			return
+		}
+		n, err := strconv.Atoi(h)
+		if err != nil || n < 1 {
+			writeError(w, r, http.StatusBadRequest, errCodeInvalidHeader, "X-Max-Orders must be a positive integer")
+			return
+		}
+		if n > maxOverrideOrders {
+			writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, fmt.Sprintf("X-Max-Orders can be at most %d", maxOverrideOrders))
+			return
+		}
+		maxOrdersOverride = n
 	}
 
-	// Validate request
-	if err := validateRequest(&req); err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusBadRequest)
-		msg := err.Error()
-		json.NewEncoder(w).Encode(ErrorResponse{Error: msg, Message: msg})
+	req, ok := decodeRequestWith(w, r, func(req *OptimizeRequest) error {
+		req.maxOrders = maxOrdersOverride
+		return validateRequest(req)
+	})
+	if !ok {
 		return
 	}
 
-	// Check cache first
-	key, err := cacheKey(&req)
-	if err == nil {
-		if cached, found := globalCache.get(key); found {
-			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("X-Cache", "HIT")
-			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(cached)
+	// Per-request override of the solver deadline
+	deadline := cfg.SolverDeadline
+	if h := r.Header.Get("X-Solver-Deadline-Ms"); h != "" {
+		ms, err := strconv.Atoi(h)
+		if err != nil || ms < 0 {
+			writeError(w, r, http.StatusBadRequest, errCodeInvalidHeader, "X-Solver-Deadline-Ms must be a non-negative integer")
 			return
 		}
+		deadline = time.Duration(ms) * time.Millisecond
 	}
 
-	// Solve optimization problem
-	response := solve(&req)
-
-	// Store in cache (5 minute TTL)
-	if err == nil {
-		globalCache.put(key, response, 5*time.Minute)
+	var opts solveOptions
+	opts.pareto = r.URL.Query().Get("pareto") == "true"
+	opts.trace = r.URL.Query().Get("trace") == "true"
+	if v := r.URL.Query().Get("stable_top_k"); v != "" {
+		k, err := strconv.Atoi(v)
+		if err != nil || k < 1 || k > maxTopK {
+			writeError(w, r, http.StatusBadRequest, errCodeInvalidQuery, fmt.Sprintf("stable_top_k must be an integer from 1 to %d", maxTopK))
+			return
+		}
+		opts.stableTopK = k
+	}
+	sortBy := r.URL.Query().Get("sort_by")
+	switch sortBy {
+	case "", lineItemSortPayout, lineItemSortWeight, lineItemSortVolume:
+	default:
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidQuery, "sort_by must be payout, weight or volume")
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Cache", "MISS")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
-}
+	if !acquireSolve(w, r) {
+		return
+	}
+	defer globalSolves.release()
 
-func validateRequest(req *OptimizeRequest) error {
-	if req.Truck.ID == "" {
-		return fmt.Errorf("truck.id is required")
+	ctx, span := tracer.Start(r.Context(), "solve", trace.WithAttributes(attribute.Int("orders.count", len(req.Orders))))
+	start := time.Now()
+	var response *OptimizeResponse
+	var key string
+	var hit bool
+	var err error
+	if opts != (solveOptions{}) {
+		// The extra output isn't part of the cache key, so these bypass the cache
+		response, err = solveWith(ctx, req, deadline, opts)
+	} else {
+		response, key, hit, err = solveCached(ctx, req, deadline)
 	}
-	if req.Truck.MaxWeightLbs <= 0 {
-		return fmt.Errorf("truck.max_weight_lbs must be positive")
+	elapsed := time.Since(start)
+	span.SetAttributes(attribute.Int64("solve.duration_ms", elapsed.Milliseconds()), attribute.Bool("cache.hit", hit))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 	}
-	if req.Truck.MaxVolumeCuft <= 0 {
-		return fmt.Errorf("truck.max_volume_cuft must be positive")
+	span.End()
+	w.Header().Set("X-Solve-Duration-Ms", strconv.FormatInt(elapsed.Milliseconds(), 10))
+	if elapsed > cfg.SlowSolveThreshold {
+		w.Header().Set("X-Solve-Slow", "true")
 	}
-	if len(req.Orders) > 22 {
-		return fmt.Errorf("too many orders (max 22)")
+	if err != nil {
+		writeSolveError(w, r, err)
+		return
 	}
-	for i, o := range req.Orders {
-		if o.ID == "" {
-			return fmt.Errorf("orders[%d].id is required", i)
-		}
-		if o.PayoutCents < 0 {
-			return fmt.Errorf("orders[%d].payout_cents must be non-negative", i)
-		}
-		if o.WeightLbs < 0 {
-			return fmt.Errorf("orders[%d].weight_lbs must be non-negative", i)
-		}
-		if o.VolumeCuft < 0 {
-			return fmt.Errorf("orders[%d].volume_cuft must be non-negative", i)
-		}
-		if o.Origin == "" {
-			return fmt.Errorf("orders[%d].origin is required", i)
-		}
-		if o.Destination == "" {
-			return fmt.Errorf("orders[%d].destination is required", i)
-		}
-		if o.PickupDate == "" {
-			return fmt.Errorf("orders[%d].pickup_date is required", i)
-		}
-		if o.DeliveryDate == "" {
-			return fmt.Errorf("orders[%d].delivery_date is required", i)
-		}
-		// Validate pickup_date <= delivery_date
-		pickup, err := time.Parse("2006-01-02", o.PickupDate)
-		if err != nil {
-			return fmt.Errorf("orders[%d].pickup_date has invalid format (expected YYYY-MM-DD): %s", i, o.PickupDate)
-		}
-		delivery, err := time.Parse("2006-01-02", o.DeliveryDate)
-		if err != nil {
-			return fmt.Errorf("orders[%d].delivery_date has invalid format (expected YYYY-MM-DD): %s", i, o.DeliveryDate)
+	if key != "" {
+		w.Header().Set("X-Cache-Key", key)
+	}
+	if hit {
+		w.Header().Set("X-Cache", "HIT")
+	} else {
+		w.Header().Set("X-Cache", "MISS")
+	}
+	includeIndices := r.URL.Query().Get("include_indices") == "true"
+	itemize := r.URL.Query().Get("itemize") == "true"
+	if includeIndices || itemize {
+		// Copy so the cached response stays as it was
+		extended := *response
+		if includeIndices {
+			extended.SelectedOrderIndices = response.selectedIndices
 		}
-		if pickup.After(delivery) {
-			return fmt.Errorf("orders[%d].pickup_date must be on or before delivery_date", i)
+		if itemize {
+			extended.LineItems = lineItems(response, req, sortBy)
 		}
+		response = &extended
 	}
-	return nil
+	if response.Diagnostics != nil && deadline > 0 {
+		// Depends on this request's deadline and timing, so it goes on a
+		// copy rather than the cached response
+		withBudget := *response
+		diagnostics := *response.Diagnostics
+		remaining := (deadline - elapsed).Milliseconds()
+		diagnostics.SolveDeadlineRemainingMs = &remaining
+		withBudget.Diagnostics = &diagnostics
+		response = &withBudget
+	}
+	if acceptsProtobuf(r) {
+		w.Header().Set("Content-Type", contentTypeProtobuf)
+		w.WriteHeader(http.StatusOK)
+		w.Write(response.marshalProto())
+		return
+	}
+	if acceptsXML(r) {
+		writeXML(w, r, http.StatusOK, response)
+		return
+	}
+	writeJSON(w, r, http.StatusOK, response)
 }
 
-// solve finds the optimal combination of orders using DP with bitmask
-func solve(req *OptimizeRequest) *OptimizeResponse {
-	opt := NewOptimizer(req.Truck, req.Orders)
-	bestMask := opt.FindOptimal()
-
-	return opt.BuildResponse(bestMask)
+// acceptsProtobuf reports whether the client asked for a protobuf response
+func acceptsProtobuf(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), contentTypeProtobuf)
 }
 
-// NewOptimizer creates a new optimizer instance
-func NewOptimizer(truck Truck, orders []Order) *Optimizer {
-	n := len(orders)
-	maxMask := 1 << n
-	opt := &Optimizer{
-		truck:   truck,
-		orders:  orders,
-		n:       n,
-		maxMask: maxMask,
-		weight:  make([]int64, maxMask),
-		volume:  make([]int64, maxMask),
-		payout:  make([]int64, maxMask),
-		valid:   make([]bool, maxMask),
+// solveCached answers from the response cache when possible, otherwise solves
+// and caches the result. It returns the cache key ("" if the request couldn't
+// be hashed) and whether the answer came from the cache.
+func solveCached(ctx context.Context, req *OptimizeRequest, deadline time.Duration) (*OptimizeResponse, string, bool, error) {
+	// Check cache first
+	key, err := cacheKey(req)
+	if err != nil {
+		key = ""
+	}
+	if key != "" {
+		_, span := tracer.Start(ctx, "cache.lookup")
+		cached, found := globalCache.get(key)
+		span.SetAttributes(attribute.Bool("cache.hit", found))
+		span.End()
+		if found {
+			return cached, key, true, nil
+		}
 	}
 
-	// Pre-compute totals for each subset using DP
-	opt.precompute()
+	// Solve optimization problem
+	response, err := solve(ctx, req, deadline)
+	if err != nil {
+		return nil, key, false, err
+	}
 
-	return opt
+	// Store in cache. Heuristic fallbacks are not cached so a later
+	// request with more time can still get the exact answer.
+	if ttl := resultTTL(response); key != "" && response.Optimal && ttl > 0 {
+		globalCache.put(key, response, ttl)
+	}
+	return response, key, false, nil
 }
 
-// precompute calculates weight, volume, payout and validity for all subsets
-// Uses subset DP: dp[mask] = dp[mask without LSB] + order[LSB index]
-// Applies pruning: subsets exceeding truck capacity are marked invalid immediately
-func (o *Optimizer) precompute() {
-	// Empty set
-	o.valid[0] = true
-	o.weight[0] = 0
-	o.volume[0] = 0
-	o.payout[0] = 0
-
-	maxWeight := o.truck.MaxWeightLbs
-	maxVolume := o.truck.MaxVolumeCuft
-
-	// For each non-empty subset
-	for mask := 1; mask < o.maxMask; mask++ {
-		// Get lowest set bit
-		lsb := mask & -mask
-		i := bitPosition(lsb)
-		prev := mask ^ lsb
-
-		o.weight[mask] = o.weight[prev] + o.orders[i].WeightLbs
-		o.volume[mask] = o.volume[prev] + o.orders[i].VolumeCuft
-		o.payout[mask] = o.payout[prev] + o.orders[i].PayoutCents
-
-		// Pruning: check capacity constraints first (fast check)
-		if o.weight[mask] > maxWeight || o.volume[mask] > maxVolume {
-			o.valid[mask] = false
-			continue
-		}
-
-		// Then check hazmat and route compatibility
-		o.valid[mask] = o.isValidSubset(mask)
+// resultTTL returns how long to cache a response. Empty selections often mean
+// the orders just aren't in yet, so they get the shorter EMPTY_RESULT_TTL.
+func resultTTL(resp *OptimizeResponse) time.Duration {
+	if len(resp.SelectedOrderIDs) == 0 {
+		return cfg.EmptyResultTTL
 	}
+	return cacheTTL
 }
 
-// isValidSubset checks if a subset of orders is compatible
-func (o *Optimizer) isValidSubset(mask int) bool {
-	if mask == 0 {
-		return true
+// clearCacheHandler empties the response cache, or drops a single entry when
+// a key is given in the path
+func clearCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w, r, http.MethodDelete)
+		return
 	}
 
-	var hasHazmat, hasNonHazmat bool
-	var origin, destination string
-
-	for i := 0; i < o.n; i++ {
-		if mask&(1<<i) == 0 {
-			continue
+	removed := 0
+	if key := r.PathValue("key"); key != "" {
+		if globalCache.remove(key) {
+			removed = 1
 		}
-		order := o.orders[i]
+	} else {
+		removed = globalCache.clear()
+	}
+	writeJSON(w, r, http.StatusOK, map[string]int{"removed": removed})
+}
 
-		// Check hazmat compatibility
-		if order.IsHazmat {
-			hasHazmat = true
-		} else {
-			hasNonHazmat = true
-		}
+// decodeOptimizeRequest reads and validates an OptimizeRequest from the body.
+// On failure it writes the error response and returns false.
+func decodeOptimizeRequest(w http.ResponseWriter, r *http.Request) (*OptimizeRequest, bool) {
+	return decodeRequestWith(w, r, validateRequest)
+}
 
-		// All orders must have same origin/destination
-		if origin == "" {
-			origin = order.Origin
-			destination = order.Destination
-		} else {
-			if !stringsEqualFold(origin, order.Origin) {
-				return false
-			}
-			if !stringsEqualFold(destination, order.Destination) {
-				return false
-			}
+// decodeRequestWith is decodeOptimizeRequest with its own validation
+func decodeRequestWith(w http.ResponseWriter, r *http.Request, validate func(*OptimizeRequest) error) (*OptimizeRequest, bool) {
+	var req OptimizeRequest
+	// Parameters such as charset are ignored
+	switch mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType {
+	case contentTypeProtobuf:
+		if !decodeProtoBody(w, r, &req) {
+			return nil, false
 		}
+	case contentTypeJSON:
+		if !decodeJSONBody(w, r, &req) {
+			return nil, false
+		}
+	default:
+		writeError(w, r, http.StatusUnsupportedMediaType, errCodeMediaType,
+			fmt.Sprintf("Content-Type must be %s or %s", contentTypeJSON, contentTypeProtobuf))
+		return nil, false
 	}
 
-	// Hazmat can only be with hazmat
-	if hasHazmat && hasNonHazmat {
-		return false
+	// Validate request; well-formed but invalid data is a 422
+	if err := validate(&req); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+		return nil, false
 	}
-
-	return true
+	return &req, true
 }
 
-// FindOptimal finds the best subset using DP
-// Capacity constraints already checked during precompute via pruning
-func (o *Optimizer) FindOptimal() int {
-	bestMask := 0
-	bestPayout := int64(0)
+// limitedBody returns the request body capped at MAX_BODY_BYTES, inflating
+// it first if it is gzip-encoded. The cap applies both to the bytes read
+// off the wire and to the decompressed bytes the decoder sees, so a small
+// body that inflates past the limit fails with *http.MaxBytesError mid-
+// stream. On failure it writes the error response and returns false.
+func limitedBody(w http.ResponseWriter, r *http.Request) (io.Reader, bool) {
+	// Reject declared oversize bodies early, and cap the actual read so
+	// chunked or mislabelled bodies can't exceed the limit either
+	if r.ContentLength > cfg.MaxBodyBytes {
+		writeError(w, r, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge, "payload too large")
+		return nil, false
+	}
+	body := http.MaxBytesReader(w, r.Body, cfg.MaxBodyBytes)
 
-	// Iterate through all subsets
-	for mask := 1; mask < o.maxMask; mask++ {
-		if !o.valid[mask] {
-			continue
-		}
-		if o.payout[mask] > bestPayout {
-			bestPayout = o.payout[mask]
-			bestMask = mask
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return body, true
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			writeError(w, r, http.StatusBadRequest, errCodeInvalidBody, "invalid gzip body: "+err.Error())
+			return nil, false
 		}
+		return http.MaxBytesReader(w, gz, cfg.MaxBodyBytes), true
+	default:
+		writeError(w, r, http.StatusUnsupportedMediaType, errCodeMediaType, "Content-Encoding must be gzip or identity")
+		return nil, false
 	}
-
-	return bestMask
 }
 
-// BuildResponse creates the response from the best mask
-func (o *Optimizer) BuildResponse(bestMask int) *OptimizeResponse {
-	orderIDs := []string{}
-	for i := 0; i < o.n; i++ {
-		if bestMask&(1<<i) != 0 {
-			orderIDs = append(orderIDs, o.orders[i].ID)
+// decodeJSONBody strictly decodes the size-limited body into v.
+// On failure it writes the error response and returns false.
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	body, ok := limitedBody(w, r)
+	if !ok {
+		return false
+	}
+
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge, "payload too large")
+			return false
 		}
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidJSON, "invalid JSON: "+err.Error())
+		return false
 	}
+	return true
+}
 
-	weightPct := 0.0
-	volumePct := 0.0
-	if o.truck.MaxWeightLbs > 0 {
-		weightPct = float64(o.weight[bestMask]) / float64(o.truck.MaxWeightLbs) * 100
+// decodeProtoBody reads the size-limited body as a protobuf OptimizeRequest.
+// On failure it writes the error response and returns false.
+func decodeProtoBody(w http.ResponseWriter, r *http.Request, req *OptimizeRequest) bool {
+	limited, ok := limitedBody(w, r)
+	if !ok {
+		return false
+	}
+	body, err := io.ReadAll(limited)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, r, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge, "payload too large")
+			return false
+		}
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidBody, "failed to read body: "+err.Error())
+		return false
 	}
-	if o.truck.MaxVolumeCuft > 0 {
-		volumePct = float64(o.volume[bestMask]) / float64(o.truck.MaxVolumeCuft) * 100
+	if err := req.unmarshalProto(body); err != nil {
+		writeError(w, r, http.StatusBadRequest, errCodeInvalidBody, "invalid protobuf: "+err.Error())
+		return false
 	}
+	return true
+}
 
-	return &OptimizeResponse{
-		TruckID:                  o.truck.ID,
-		SelectedOrderIDs:         orderIDs,
-		TotalPayoutCents:         o.payout[bestMask],
-		TotalWeightLbs:           o.weight[bestMask],
-		TotalVolumeCuft:          o.volume[bestMask],
-		UtilizationWeightPercent: roundTo2Decimals(weightPct),
-		UtilizationVolumePercent: roundTo2Decimals(volumePct),
+const contentTypeJSON = "application/json"
+
+// writeJSON writes v as a JSON body with the given status code.
+// ?pretty=true indents the output for reading in a terminal.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	if pretty, _ := strconv.ParseBool(r.URL.Query().Get("pretty")); pretty {
+		enc.SetIndent("", "  ")
 	}
+	enc.Encode(v)
 }
 
-// bitPosition returns the position of the single set bit (0-indexed)
-func bitPosition(x int) int {
-	pos := 0
-	for x > 1 {
-		x >>= 1
-		pos++
+// writeError writes an ErrorResponse with the given status code
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	if acceptsXML(r) {
+		writeXML(w, r, status, ErrorResponse{Error: msg, Message: msg, Code: code, RequestID: requestIDFrom(r.Context())})
+		return
 	}
-	return pos
+	writeJSON(w, r, status, ErrorResponse{Error: msg, Message: msg, Code: code, RequestID: requestIDFrom(r.Context())})
 }
 
-func stringsEqualFold(a, b string) bool {
-	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+// methodNotAllowed writes a 405 through the same JSON path as other errors
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed string) {
+	w.Header().Set("Allow", allowed)
+	writeError(w, r, http.StatusMethodNotAllowed, errCodeMethod, "method not allowed")
 }
 
-func roundTo2Decimals(x float64) float64 {
-	return float64(int64(x*100+0.5)) / 100
+// now returns the current time; tests can replace it for deterministic dates
+var now = time.Now
+
+// today returns the current UTC date at midnight, comparable with parsed order dates
+func today() time.Time {
+	y, m, d := now().UTC().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// maxRelativeDays bounds N in "today+N" so resolved dates stay four-digit years
+const maxRelativeDays = 3650
+
+// parseOrderDate accepts YYYY-MM-DD, or a date relative to today() as
+// "today" or "today+N" days, which some upstream feeds send
+func parseOrderDate(s string) (time.Time, bool) {
+	if rest, ok := strings.CutPrefix(s, "today"); ok {
+		if rest == "" {
+			return today(), true
+		}
+		days, err := strconv.ParseUint(strings.TrimPrefix(rest, "+"), 10, 16)
+		if rest[0] != '+' || err != nil || days > maxRelativeDays {
+			return time.Time{}, false
+		}
+		return today().AddDate(0, 0, int(days)), true
+	}
+	t, err := time.Parse("2006-01-02", s)
+	return t, err == nil
+}
+
+// validateOrderGroups checks that every grouped order ID exists
+func validateOrderGroups(req *OptimizeRequest) error {
+	ids := make(map[string]bool, len(req.Orders))
+	for _, o := range req.Orders {
+		ids[o.ID] = true
+	}
+	for i, g := range req.OrderGroups {
+		if len(g) == 0 {
+			return fmt.Errorf("order_groups[%d] must not be empty", i)
+		}
+		for _, id := range g {
+			if !ids[id] {
+				return fmt.Errorf("order_groups[%d] references unknown order id %q", i, id)
+			}
+		}
+	}
+	for i, pair := range req.IncompatiblePairs {
+		if len(pair) != 2 {
+			return fmt.Errorf("incompatible_pairs[%d] must have exactly 2 order ids", i)
+		}
+		for _, id := range pair {
+			if !ids[id] {
+				return fmt.Errorf("incompatible_pairs[%d] references unknown order id %q", i, id)
+			}
+		}
+		if pair[0] == pair[1] {
+			return fmt.Errorf("incompatible_pairs[%d] must name two different orders", i)
+		}
+	}
+	for i, b := range req.CoLoadBonuses {
+		for _, id := range []string{b.OrderA, b.OrderB} {
+			if !ids[id] {
+				return fmt.Errorf("co_load_bonuses[%d] references unknown order id %q", i, id)
+			}
+		}
+		if b.OrderA == b.OrderB {
+			return fmt.Errorf("co_load_bonuses[%d] must name two different orders", i)
+		}
+		if b.BonusCents < 0 {
+			return fmt.Errorf("co_load_bonuses[%d].bonus_cents must be non-negative", i)
+		}
+	}
+	for i, id := range req.PreferredOrderIDs {
+		if !ids[id] {
+			return fmt.Errorf("preferred_order_ids[%d] references unknown order id %q", i, id)
+		}
+	}
+	for i, id := range req.PinnedOrderIDs {
+		if !ids[id] {
+			return fmt.Errorf("pinned_order_ids[%d] references unknown order id %q", i, id)
+		}
+	}
+	for i, id := range req.BaselineOrderIDs {
+		if !ids[id] {
+			return fmt.Errorf("baseline_order_ids[%d] references unknown order id %q", i, id)
+		}
+	}
+	if req.MinTotalPayoutCents < 0 {
+		return fmt.Errorf("min_total_payout_cents must be non-negative")
+	}
+	if req.PreferenceBonusCents < 0 {
+		return fmt.Errorf("preference_bonus_cents must be non-negative")
+	}
+	return nil
+}
+
+// validateRequest checks a request the solvers can take, including the
+// order cap their bitmasks need, or the request's X-Max-Orders
+func validateRequest(req *OptimizeRequest) error {
+	if err := validateRequestFields(req); err != nil {
+		return err
+	}
+	limit := cfg.orderCap()
+	if req.maxOrders > 0 {
+		limit = req.maxOrders
+	}
+	if len(req.Orders) > limit {
+		return fmt.Errorf("too many orders (max %d)", limit)
+	}
+	return nil
+}
+
+// validateRequestFields checks everything validateRequest does but the order
+// cap, for checks that never build a bitmask over the orders
+func validateRequestFields(req *OptimizeRequest) error {
+	if req.Truck.ID == "" {
+		return fmt.Errorf("truck.id is required")
+	}
+	if req.Truck.MaxWeightLbs <= 0 && !req.IgnoreWeight {
+		return fmt.Errorf("truck.max_weight_lbs must be positive")
+	}
+	if req.Truck.MaxVolumeCuft <= 0 && !req.IgnoreVolume {
+		return fmt.Errorf("truck.max_volume_cuft must be positive")
+	}
+	if req.Truck.TransitDays < 0 {
+		return fmt.Errorf("truck.transit_days must be non-negative")
+	}
+	if req.Truck.WeightOveragePercent < 0 || req.Truck.WeightOveragePercent > 100 {
+		return fmt.Errorf("truck.weight_overage_percent must be between 0 and 100")
+	}
+	if req.Truck.OveragePenaltyCentsPerLb < 0 {
+		return fmt.Errorf("truck.overage_penalty_cents_per_lb must be non-negative")
+	}
+	if req.Truck.CO2GramsPerMile < 0 {
+		return fmt.Errorf("truck.co2_grams_per_mile must be non-negative")
+	}
+	if req.Truck.FixedCostCents < 0 {
+		return fmt.Errorf("truck.fixed_cost_cents must be non-negative")
+	}
+	if req.Truck.PreloadedWeightLbs < 0 {
+		return fmt.Errorf("truck.preloaded_weight_lbs must be non-negative")
+	}
+	if req.Truck.PreloadedVolumeCuft < 0 {
+		return fmt.Errorf("truck.preloaded_volume_cuft must be non-negative")
+	}
+	if !req.IgnoreWeight && req.Truck.PreloadedWeightLbs > req.Truck.MaxWeightLbs {
+		return fmt.Errorf("truck.preloaded_weight_lbs can't exceed max_weight_lbs")
+	}
+	if !req.IgnoreVolume && req.Truck.PreloadedVolumeCuft > req.Truck.MaxVolumeCuft {
+		return fmt.Errorf("truck.preloaded_volume_cuft can't exceed max_volume_cuft")
+	}
+	if req.IgnoreWeight && (req.Truck.WeightOveragePercent > 0 || req.Truck.OveragePenaltyCentsPerLb > 0) {
+		return fmt.Errorf("weight overage settings can't be combined with ignore_weight")
+	}
+	if !req.IgnoreWeight && !overageFits(req.Truck.weightCap()-req.Truck.freeWeightLbs(), req.Truck.OveragePenaltyCentsPerLb) {
+		return fmt.Errorf("truck.overage_penalty_cents_per_lb is too large: the penalty for the full overage exceeds %d cents", int64(math.MaxInt64))
+	}
+	if len(req.Truck.AxleCapacities) > maxAxles {
+		return fmt.Errorf("truck.axle_capacities may have at most %d axle groups", maxAxles)
+	}
+	if req.IgnoreWeight && len(req.Truck.AxleCapacities) > 0 {
+		return fmt.Errorf("truck.axle_capacities can't be combined with ignore_weight")
+	}
+	for i, capacity := range req.Truck.AxleCapacities {
+		if capacity <= 0 {
+			return fmt.Errorf("truck.axle_capacities[%d] must be positive", i)
+		}
+	}
+	if req.MaxOrigins < 0 {
+		return fmt.Errorf("max_origins must be non-negative")
+	}
+	if req.MaxOrigins > 0 && !req.AllowMultiOrigin {
+		return fmt.Errorf("max_origins requires allow_multi_origin")
+	}
+	if req.MaxDestinations < 0 {
+		return fmt.Errorf("max_destinations must be non-negative")
+	}
+	if req.MaxDestinations > 0 && !req.AllowMultiDestination {
+		return fmt.Errorf("max_destinations requires allow_multi_destination")
+	}
+	if req.MaxOrdersPerDestination < 0 {
+		return fmt.Errorf("max_orders_per_destination must be non-negative")
+	}
+	if req.MaxCategoryPercent < 0 || req.MaxCategoryPercent > 100 {
+		return fmt.Errorf("max_category_percent must be between 0 and 100")
+	}
+	if len(req.PricingSnapshotID) > 128 {
+		return fmt.Errorf("pricing_snapshot_id must be at most 128 characters")
+	}
+	switch req.GreedySort {
+	case "", greedySortPayoutPerWeight, greedySortPayoutPerVolume, greedySortPayout:
+	default:
+		return fmt.Errorf("greedy_sort must be %q, %q or %q", greedySortPayoutPerWeight, greedySortPayoutPerVolume, greedySortPayout)
+	}
+	switch req.Solver {
+	case "", solverExact, solverGreedy, solverMeetInMiddle:
+	default:
+		return fmt.Errorf("solver must be %q, %q or %q", solverExact, solverGreedy, solverMeetInMiddle)
+	}
+	switch req.Objective {
+	case "", objectiveMaximinMargin:
+	case objectiveTargetUtilization:
+		if req.IgnoreWeight {
+			return fmt.Errorf("objective %q can't be combined with ignore_weight", objectiveTargetUtilization)
+		}
+		if req.TargetWeightPercent <= 0 || req.TargetWeightPercent > 100 {
+			return fmt.Errorf("target_weight_percent must be above 0 and at most 100")
+		}
+	default:
+		return fmt.Errorf("objective must be %q or %q", objectiveMaximinMargin, objectiveTargetUtilization)
+	}
+	if req.TargetWeightPercent != 0 && req.Objective != objectiveTargetUtilization {
+		return fmt.Errorf("target_weight_percent requires objective %q", objectiveTargetUtilization)
+	}
+	if err := validateOrderGroups(req); err != nil {
+		return err
+	}
+	// Rules and plans name orders by ID, so a repeated one would silently
+	// resolve to just one of them
+	seen := make(map[string]int, len(req.Orders))
+	for i, o := range req.Orders {
+		if o.ID == "" {
+			return fmt.Errorf("orders[%d].id is required", i)
+		}
+		if j, dup := seen[o.ID]; dup {
+			return fmt.Errorf("orders[%d].id %q is already used by orders[%d]", i, o.ID, j)
+		}
+		seen[o.ID] = i
+		if o.PayoutCents < 0 {
+			return fmt.Errorf("orders[%d].payout_cents must be non-negative", i)
+		}
+		if o.WeightLbs < 0 {
+			return fmt.Errorf("orders[%d].weight_lbs must be non-negative", i)
+		}
+		if o.VolumeCuft < 0 {
+			return fmt.Errorf("orders[%d].volume_cuft must be non-negative", i)
+		}
+		if o.DistanceMiles < 0 {
+			return fmt.Errorf("orders[%d].distance_miles must be non-negative", i)
+		}
+		if o.CostCents < 0 {
+			return fmt.Errorf("orders[%d].cost_cents must be non-negative", i)
+		}
+		if o.AxlePosition < 0 || o.AxlePosition > 0 && o.AxlePosition >= len(req.Truck.AxleCapacities) {
+			return fmt.Errorf("orders[%d].axle_position must be an index into truck.axle_capacities", i)
+		}
+		if o.Origin == "" {
+			return fmt.Errorf("orders[%d].origin is required", i)
+		}
+		if o.Destination == "" {
+			return fmt.Errorf("orders[%d].destination is required", i)
+		}
+		if o.PickupDate == "" {
+			return fmt.Errorf("orders[%d].pickup_date is required", i)
+		}
+		if o.DeliveryDate == "" {
+			return fmt.Errorf("orders[%d].delivery_date is required", i)
+		}
+		// Validate pickup_date <= delivery_date
+		pickup, ok := parseOrderDate(o.PickupDate)
+		if !ok {
+			return fmt.Errorf("orders[%d].pickup_date has invalid format (expected YYYY-MM-DD): %s", i, o.PickupDate)
+		}
+		delivery, ok := parseOrderDate(o.DeliveryDate)
+		if !ok {
+			return fmt.Errorf("orders[%d].delivery_date has invalid format (expected YYYY-MM-DD): %s", i, o.DeliveryDate)
+		}
+		// Store relative dates as absolute ones so the solver and cache
+		// only ever see YYYY-MM-DD
+		req.Orders[i].PickupDate = pickup.Format("2006-01-02")
+		req.Orders[i].DeliveryDate = delivery.Format("2006-01-02")
+		if pickup.After(delivery) {
+			return fmt.Errorf("orders[%d].pickup_date must be on or before delivery_date", i)
+		}
+		if cfg.RejectPastDates && delivery.Before(today()) {
+			return fmt.Errorf("orders[%d].delivery_date is in the past: %s", i, o.DeliveryDate)
+		}
+	}
+	if err := validateCurrency(req); err != nil {
+		return err
+	}
+	if err := validatePayoutTotal(req); err != nil {
+		return err
+	}
+	return validateUnits(req)
+}
+
+// validatePayoutTotal rejects payouts whose sum doesn't fit in an int64.
+// Every load's score is at most the payouts, co-load bonuses and preference
+// bonuses of all orders together, so if that total fits, no subset sum in
+// the DP, greedy or baseline paths can wrap around to a negative payout.
+func validatePayoutTotal(req *OptimizeRequest) error {
+	var total int64
+	add := func(v int64) bool {
+		sum, carry := bits.Add64(uint64(total), uint64(v), 0)
+		if carry != 0 || sum > math.MaxInt64 {
+			return false
+		}
+		total = int64(sum)
+		return true
+	}
+	for _, o := range req.Orders {
+		if !add(o.PayoutCents) {
+			return fmt.Errorf("sum of payout_cents exceeds %d", int64(math.MaxInt64))
+		}
+	}
+	for _, b := range req.CoLoadBonuses {
+		if !add(b.BonusCents) {
+			return fmt.Errorf("sum of payout_cents and co_load_bonuses exceeds %d", int64(math.MaxInt64))
+		}
+	}
+	for range req.PreferredOrderIDs {
+		if !add(req.PreferenceBonusCents) {
+			return fmt.Errorf("sum of payout_cents and bonuses exceeds %d", int64(math.MaxInt64))
+		}
+	}
+	return nil
+}
+
+// solve finds the optimal combination of orders using DP with bitmask.
+// If deadline is non-zero and the DP doesn't finish in time, it falls back
+// to the greedy solver and the response is flagged as not optimal.
+func solve(ctx context.Context, req *OptimizeRequest, deadline time.Duration) (*OptimizeResponse, error) {
+	return solveWith(ctx, req, deadline, solveOptions{})
+}
+
+// Solvers a request can force with the solver field
+const (
+	solverExact        = "exact"
+	solverGreedy       = "greedy"
+	solverMeetInMiddle = "meet_in_middle"
+)
+
+// errSolverRefused means the solver the client forced can't handle the
+// request. It's reported as a validation error, since the client chose it.
+var errSolverRefused = errors.New("forced solver can't handle this request")
+
+// solveOptions asks for extra output that needs the DP tables
+type solveOptions struct {
+	// pareto adds the payout/capacity Pareto frontier to exact answers
+	pareto bool
+	// stableTopK, when set, adds the orders shared by the best that many
+	// loads to exact answers
+	stableTopK int
+	// trace adds an audit record of the exact solver's decisions
+	trace bool
+}
+
+// solveWith is solve with the extra output opts asks for. It records a
+// solver span under ctx, marked with whether the answer is optimal or with
+// the error it failed with.
+func solveWith(ctx context.Context, req *OptimizeRequest, deadline time.Duration, opts solveOptions) (result *OptimizeResponse, err error) {
+	_, span := tracer.Start(ctx, "solver", trace.WithAttributes(attribute.Int("orders.count", len(req.Orders))))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		} else {
+			span.SetAttributes(attribute.Bool("solver.optimal", result.Optimal))
+		}
+		span.End()
+	}()
+
+	var until time.Time
+	if deadline > 0 {
+		until = time.Now().Add(deadline)
+	}
+
+	internal := toInternalUnits(req)
+
+	// No orders is a valid request: return an empty plan with zero totals
+	// and 0% utilization without building the DP tables
+	if len(internal.Orders) == 0 {
+		resp := (&Optimizer{truck: internal.Truck}).BuildResponse(0)
+		resp.Optimal = true
+		finishResponse(resp, req)
+		return resp, nil
+	}
+
+	if req.Solver == solverGreedy {
+		resp := withMinPayout(withBaseline(greedyResponse(internal), internal), internal)
+		finishResponse(resp, req)
+		return resp, nil
+	}
+
+	if req.Solver == solverMeetInMiddle {
+		if req.Objective != "" {
+			return nil, fmt.Errorf("%w: meet_in_middle solver only maximizes the total score", errSolverRefused)
+		}
+		if len(internal.Orders) > maxMeetInMiddleOrders {
+			return nil, fmt.Errorf("%w: meet_in_middle solver takes at most %d orders", errSolverRefused, maxMeetInMiddleOrders)
+		}
+		if subsets := meetInMiddleSubsets(len(internal.Orders)); subsets > cfg.MaxSubsets {
+			return nil, fmt.Errorf("%w: meet_in_middle solver needs %d subsets, more than MAX_SUBSETS allows", errSolverRefused, subsets)
+		}
+		resp := meetInMiddleResponse(internal, until)
+		if resp == nil {
+			return nil, fmt.Errorf("%w: meet_in_middle solver did not finish within the solver deadline or MAX_SUBSETS pair checks", errSolverRefused)
+		}
+		finishResponse(resp, req)
+		return resp, nil
+	}
+
+	// What-if edits of a pinned plan search near it instead of solving from
+	// scratch, unless the search would cover every load anyway
+	if req.Solver == "" && req.Objective == "" && len(internal.PinnedOrderIDs) > 0 && len(internal.Orders) > localSearchRadius {
+		local := baseOptimizer(internal)
+		resp := local.BuildDispatch(local.FindLocal(idMask(internal.Orders, internal.PinnedOrderIDs)))
+		bound := local.optimalityBoundPercent(resp.TotalPayoutCents)
+		resp.OptimalityBoundPercent = &bound
+		resp = withMinPayout(withBaseline(resp, internal), internal)
+		finishResponse(resp, req)
+		return resp, nil
+	}
+
+	// Past EXACT_MAX_ORDERS the chooser tries meet-in-the-middle up to
+	// MIM_MAX_ORDERS and greedy after that; X-Max-Orders replaces both with
+	// exact up to its own limit. /estimate predicts the path
+	// with the same solverPath. Meet-in-the-middle only maximizes the total
+	// score, so other objectives, and searches that give up, fall back to
+	// greedy.
+	path := solverPath(len(internal.Orders), req.maxOrders)
+	if req.Solver == "" && path == estimatePathMeetInMiddle {
+		if req.Objective == "" {
+			if resp := meetInMiddleResponse(internal, until); resp != nil {
+				finishResponse(resp, req)
+				return resp, nil
+			}
+		}
+		path = estimatePathGreedy
+	}
+	if req.Solver == "" && path == estimatePathGreedy {
+		resp := withMinPayout(withBaseline(greedyResponse(internal), internal), internal)
+		finishResponse(resp, req)
+		return resp, nil
+	}
+
+	opt, err := newOptimizer(internal, until)
+	if err != nil {
+		if req.Solver == solverExact && errors.Is(err, errTooManySubsets) {
+			return nil, fmt.Errorf("%w: exact solver needs 2^%d subsets, more than MAX_SUBSETS allows", errSolverRefused, len(internal.Orders))
+		}
+		return nil, err
+	}
+	bestMask := opt.FindOptimal()
+
+	var resp *OptimizeResponse
+	if opt.timedOut {
+		if req.Solver == solverExact {
+			return nil, fmt.Errorf("%w: exact solver did not finish within the solver deadline", errSolverRefused)
+		}
+		resp = withMinPayout(withBaseline(greedyResponse(internal), internal), internal)
+	} else {
+		resp = withMinPayout(withBaseline(opt.BuildDispatch(bestMask), internal), internal)
+		if !resp.UsedBaseline {
+			resp.Optimal = true
+			resp.Diagnostics = opt.diagnostics()
+			if !resp.NotWorthDispatching && !resp.BelowMinPayout {
+				resp.MarginOverRunnerUpCents = opt.marginOverRunnerUp(bestMask)
+			}
+		}
+		if opts.pareto {
+			resp.ParetoFrontier = opt.paretoFrontier(req)
+		}
+		if opts.stableTopK > 0 && !resp.NotWorthDispatching && !resp.BelowMinPayout {
+			resp.StableOrderIDs = opt.stableOrderIDs(req, opts.stableTopK)
+		}
+		if opts.trace {
+			resp.Trace = opt.trace(req, bestMask)
+		}
+	}
+
+	finishResponse(resp, req)
+	return resp, nil
+}
+
+// withBaseline returns the request's baseline plan in place of resp when the
+// baseline is a feasible load that pays more. An infeasible baseline is
+// ignored with a warning. A returned baseline isn't the solver's answer, so
+// it is never marked optimal.
+func withBaseline(resp *OptimizeResponse, internal *OptimizeRequest) *OptimizeResponse {
+	if len(internal.BaselineOrderIDs) == 0 {
+		return resp
+	}
+	o := baseOptimizer(internal)
+	mask := idMask(internal.Orders, internal.BaselineOrderIDs)
+	if !o.fits(mask) || !o.isValidSubset(mask) || !o.complete(mask) {
+		resp.Warnings = append(resp.Warnings, "baseline_order_ids is not a feasible load and was ignored")
+		return resp
+	}
+	baseline := o.BuildResponse(mask)
+	if baseline.TotalPayoutCents <= resp.TotalPayoutCents {
+		return resp
+	}
+	baseline.UsedBaseline = true
+	return baseline
+}
+
+// withMinPayout swaps resp for the empty plan when it pays less than the
+// request's min_total_payout_cents. Unlike the fixed-cost check it looks at
+// the payout alone, after any baseline has been considered.
+func withMinPayout(resp *OptimizeResponse, internal *OptimizeRequest) *OptimizeResponse {
+	if internal.MinTotalPayoutCents == 0 || len(resp.SelectedOrderIDs) == 0 || resp.TotalPayoutCents >= internal.MinTotalPayoutCents {
+		return resp
+	}
+	empty := baseOptimizer(internal).BuildResponse(0)
+	empty.BelowMinPayout = true
+	return empty
+}
+
+// greedyResponse answers with the greedy solver and reports how close it is
+// guaranteed to be to optimal
+func greedyResponse(internal *OptimizeRequest) *OptimizeResponse {
+	greedy := baseOptimizer(internal)
+	resp := greedy.BuildDispatch(greedy.FindGreedy())
+	bound := greedy.optimalityBoundPercent(resp.TotalPayoutCents)
+	resp.OptimalityBoundPercent = &bound
+	return resp
+}
+
+// finishResponse converts totals back to the request's units and echoes the
+// request metadata every solver path reports
+func finishResponse(resp *OptimizeResponse, req *OptimizeRequest) {
+	fromInternalUnits(resp, req)
+	resp.AxleLoads = axleLoads(resp, req)
+	resp.BestAddition = bestAddition(resp, req)
+	resp.PricingSnapshotID = req.PricingSnapshotID
+	resp.Currency = requestCurrency(req)
+	resp.SolutionHash = solutionHash(resp)
+}
+
+// solutionHash identifies a plan by its orders and totals. IDs are sorted
+// first so the same plan hashes the same whatever order it was found in.
+func solutionHash(resp *OptimizeResponse) string {
+	ids := slices.Clone(resp.SelectedOrderIDs)
+	slices.Sort(ids)
+	h := sha256.New()
+	for _, id := range ids {
+		// Length-prefixed so IDs can't run into each other
+		fmt.Fprintf(h, "%d:%s", len(id), id)
+	}
+	fmt.Fprintf(h, "%d %s %s", resp.TotalPayoutCents, resp.TotalWeightLbs, resp.TotalVolumeCuft)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// NewOptimizer creates a new optimizer instance
+func NewOptimizer(truck Truck, orders []Order) (*Optimizer, error) {
+	return newOptimizer(&OptimizeRequest{Truck: truck, Orders: orders}, time.Time{})
+}
+
+// baseOptimizer sets up the orders and request-level constraints without the
+// DP tables. Heuristic solvers use it directly.
+func baseOptimizer(req *OptimizeRequest) *Optimizer {
+	weightCap, volumeCap := req.Truck.weightCap(), req.Truck.freeVolumeCuft()
+	if req.IgnoreWeight {
+		weightCap = math.MaxInt64
+	}
+	if req.IgnoreVolume {
+		volumeCap = math.MaxInt64
+	}
+	return &Optimizer{
+		truck:              req.Truck,
+		orders:             req.Orders,
+		n:                  len(req.Orders),
+		weightCap:          weightCap,
+		volumeCap:          volumeCap,
+		ignoreWeight:       req.IgnoreWeight,
+		ignoreVolume:       req.IgnoreVolume,
+		groups:             groupMasks(req.Orders, req.OrderGroups),
+		conflicts:          conflictMasks(req.Orders, req.IncompatiblePairs),
+		coLoadPairs:        coLoadMasks(req.Orders, req.CoLoadBonuses),
+		coLoadCents:        coLoadCents(req.CoLoadBonuses),
+		preferred:          idMask(req.Orders, req.PreferredOrderIDs),
+		preferenceBonus:    req.PreferenceBonusCents,
+		greedySort:         req.GreedySort,
+		objective:          req.Objective,
+		targetWeight:       Quantity(float64(req.Truck.freeWeightLbs()) * req.TargetWeightPercent / 100),
+		tieBreakByDelivery: req.TieBreakByDelivery,
+		seed:               req.Seed,
+		maxOrigins:         stopLimit(req.AllowMultiOrigin, req.MaxOrigins),
+		maxDestinations:    stopLimit(req.AllowMultiDestination, req.MaxDestinations),
+		maxPerDest:         req.MaxOrdersPerDestination,
+		sameDest:           sameDestMasks(req.Orders, req.MaxOrdersPerDestination),
+		maxCategoryPercent: req.MaxCategoryPercent,
+		categoryMasks:      categoryMasks(req.Orders, req.MaxCategoryPercent),
+		axleMasks:          axleMasks(req.Orders, len(req.Truck.AxleCapacities)),
+	}
+}
+
+// sameDestMasks returns, per order, a bitmask of the orders dropped at the
+// same destination, or nil when there is no per-destination cap
+func sameDestMasks(orders []Order, limit int) []int {
+	if limit == 0 {
+		return nil
+	}
+	ids := placeIDs(orders, func(order Order) string { return order.Destination })
+	masks := make([]int, len(orders))
+	for i := range orders {
+		for j := range orders {
+			if ids[i] == ids[j] {
+				masks[i] |= 1 << j
+			}
+		}
+	}
+	return masks
+}
+
+// stopLimit returns how many distinct origins or destinations one load may
+// have, given the request's allow flag and max (0 = no limit)
+func stopLimit(allow bool, limit int) int {
+	if !allow {
+		return 1
+	}
+	if limit == 0 {
+		return math.MaxInt32
+	}
+	return limit
+}
+
+// subsetLimit is the largest DP table a request may build: MAX_SUBSETS, or
+// 2^override when an admin set X-Max-Orders
+func subsetLimit(override int) int64 {
+	if override > 0 {
+		return int64(1) << override
+	}
+	return cfg.MaxSubsets
+}
+
+// errTooManySubsets means the DP tables would exceed MAX_SUBSETS
+var errTooManySubsets = errors.New("request needs more subsets than MAX_SUBSETS allows")
+
+// newOptimizer creates an optimizer whose DP gives up once deadline passes.
+// It refuses requests over maxOrders (or the request's X-Max-Orders), or
+// whose tables would exceed MAX_SUBSETS, rather than risk running the
+// process out of memory.
+func newOptimizer(req *OptimizeRequest, deadline time.Time) (*Optimizer, error) {
+	opt := baseOptimizer(req)
+	if opt.n > max(maxOrders, req.maxOrders) || int64(1)<<opt.n > subsetLimit(req.maxOrders) {
+		return nil, errTooManySubsets
+	}
+	opt.deadline = deadline
+	opt.buildTables()
+	return opt, nil
+}
+
+// buildTables allocates the DP tables for every subset of the orders and
+// fills them in
+func (o *Optimizer) buildTables() {
+	maxMask := 1 << o.n
+	o.maxMask = maxMask
+	o.weight = make([]Quantity, maxMask)
+	o.volume = make([]Quantity, maxMask)
+	o.payout = make([]int64, maxMask)
+	o.valid = make([]bool, maxMask)
+	o.hazmat = make([]uint8, maxMask)
+	o.origins = make([]uint32, maxMask)
+	o.dests = make([]uint32, maxMask)
+	if o.needsSchedule() {
+		o.latestPickup = make([]int32, maxMask)
+		o.earliestDelivery = make([]int32, maxMask)
+	}
+
+	// Pre-compute totals for each subset using DP
+	o.precompute()
+}
+
+// Per-subset hazmat markers
+const (
+	hazmatFlag    uint8 = 1 << 0
+	nonHazmatFlag uint8 = 1 << 1
+)
+
+// precompute calculates weight, volume, payout and validity for all subsets
+// Uses subset DP: dp[mask] = dp[mask without its top bit] + order[top bit]
+// Hazmat presence, origins and destinations are carried the same way, so the
+// compatibility check is O(1) per mask instead of a walk over its bits.
+// Applies pruning: validity is monotonic (capacity only grows and a hazmat,
+// route or schedule conflict can't be undone by adding orders), so subsets
+// are built in increasing popcount order by extending only the valid subsets
+// of the previous level. Supersets of an invalid subset are never visited and
+// stay invalid; visited ones go through the route, hazmat, schedule and
+// capacity checks in that order.
+func (o *Optimizer) precompute() {
+	// Empty set
+	o.valid[0] = true
+	o.weight[0] = 0
+	o.volume[0] = 0
+	o.payout[0] = 0
+
+	o.originIDs = placeIDs(o.orders, func(order Order) string { return order.Origin })
+	o.destIDs = placeIDs(o.orders, func(order Order) string { return order.Destination })
+
+	if o.latestPickup != nil {
+		o.pickupDays, o.deliveryDays = o.orderDays()
+		o.latestPickup[0] = math.MinInt32
+		o.earliestDelivery[0] = math.MaxInt32
+	}
+
+	// Each level holds the valid subsets with the same number of orders.
+	// Adding only orders above a subset's top bit reaches every subset of
+	// the next level exactly once.
+	level := []int{0}
+	for len(level) > 0 {
+		var next []int
+		for _, prev := range level {
+			for i := bits.Len(uint(prev)); i < o.n; i++ {
+				o.visited++
+				if o.expired(o.visited) {
+					return
+				}
+				mask := prev | 1<<i
+				if o.extend(mask, prev, i) {
+					o.valid[mask] = true
+					next = append(next, mask)
+				}
+			}
+		}
+		level = next
+	}
+}
+
+// extend fills in the tables for mask, which is the valid subset prev plus
+// order i, and reports whether mask is valid
+func (o *Optimizer) extend(mask, prev, i int) bool {
+	// Saturate so orders too big to fit can't wrap around to a small sum
+	o.weight[mask] = addSaturating(o.weight[prev], o.orders[i].WeightLbs)
+	o.volume[mask] = addSaturating(o.volume[prev], o.orders[i].VolumeCuft)
+	o.payout[mask] = o.payout[prev] + o.orders[i].PayoutCents
+
+	// Route mismatch: the load must stay within the allowed number of
+	// origins and destinations
+	o.origins[mask] = o.origins[prev] | 1<<o.originIDs[i]
+	o.dests[mask] = o.dests[prev] | 1<<o.destIDs[i]
+	numOrigins := bits.OnesCount32(o.origins[mask])
+	numDests := bits.OnesCount32(o.dests[mask])
+	if numOrigins > o.maxOrigins || numDests > o.maxDestinations {
+		return false
+	}
+	if o.maxPerDest > 0 && bits.OnesCount(uint(mask&o.sameDest[i])) > o.maxPerDest {
+		return false
+	}
+
+	// Forbidden pairs
+	if o.conflicts != nil && prev&o.conflicts[i] != 0 {
+		return false
+	}
+
+	// Hazmat can only be with hazmat, and only on a certified truck
+	if o.orders[i].IsHazmat && !o.truck.allowsHazmat() {
+		return false
+	}
+	if o.orders[i].IsHazmat {
+		o.hazmat[mask] = o.hazmat[prev] | hazmatFlag
+	} else {
+		o.hazmat[mask] = o.hazmat[prev] | nonHazmatFlag
+	}
+	if o.hazmat[mask] == hazmatFlag|nonHazmatFlag {
+		return false
+	}
+
+	// Schedule: the truck leaves after the last pickup and must arrive
+	// before the first delivery deadline. Single-stop loads without
+	// transit_days keep the plain per-order date check.
+	if o.pickupDays != nil {
+		transit := o.truck.TransitDays
+		o.latestPickup[mask] = max(o.latestPickup[prev], o.pickupDays[i])
+		o.earliestDelivery[mask] = min(o.earliestDelivery[prev], o.deliveryDays[i])
+		if (transit > 0 || numOrigins > 1 || numDests > 1) && int64(o.latestPickup[mask])+transit > int64(o.earliestDelivery[mask]) {
+			return false
+		}
+	}
+
+	// Axle limits
+	if o.axleMasks != nil && !o.axleFits(prev, i) {
+		return false
+	}
+
+	// Capacity constraints
+	return o.weight[mask] <= o.weightCap && o.volume[mask] <= o.volumeCap
+}
+
+// needsSchedule reports whether pickup/delivery windows must be tracked
+func (o *Optimizer) needsSchedule() bool {
+	return o.truck.TransitDays > 0 || o.maxOrigins > 1 || o.maxDestinations > 1
+}
+
+// placeIDs assigns each order a 0-based ID shared by all orders with the same
+// place, using the same comparison as isValidSubset
+func placeIDs(orders []Order, place func(Order) string) []int32 {
+	ids := make([]int32, len(orders))
+	var reps []string // first spelling seen for each place
+	for i, order := range orders {
+		ids[i] = -1
+		for id, r := range reps {
+			if stringsEqualFold(place(order), r) {
+				ids[i] = int32(id)
+				break
+			}
+		}
+		if ids[i] < 0 {
+			ids[i] = int32(len(reps))
+			reps = append(reps, place(order))
+		}
+	}
+	return ids
+}
+
+// orderDays returns each order's pickup and delivery dates as day numbers.
+// Dates are already validated, so parse errors can't occur here.
+func (o *Optimizer) orderDays() (pickup, delivery []int32) {
+	pickup = make([]int32, o.n)
+	delivery = make([]int32, o.n)
+	for i, order := range o.orders {
+		p, _ := time.Parse("2006-01-02", order.PickupDate)
+		d, _ := time.Parse("2006-01-02", order.DeliveryDate)
+		pickup[i] = int32(p.Unix() / 86400)
+		delivery[i] = int32(d.Unix() / 86400)
+	}
+	return pickup, delivery
+}
+
+// isValidSubset checks if a subset of orders is compatible.
+// precompute derives the same result incrementally; this is used by
+// solvers that don't build the DP tables.
+func (o *Optimizer) isValidSubset(mask int) bool {
+	if mask == 0 {
+		return true
+	}
+
+	var hasHazmat, hasNonHazmat bool
+	var origins, destinations []string
+	var pickupDays, deliveryDays []int32
+	latestPickup, earliestDelivery := int32(math.MinInt32), int32(math.MaxInt32)
+	if o.needsSchedule() {
+		// Solvers that check many masks this way parse the dates once
+		if o.pickupDays == nil {
+			o.pickupDays, o.deliveryDays = o.orderDays()
+		}
+		pickupDays, deliveryDays = o.pickupDays, o.deliveryDays
+	}
+
+	for i := 0; i < o.n; i++ {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		order := o.orders[i]
+
+		if o.conflicts != nil && mask&o.conflicts[i] != 0 {
+			return false
+		}
+		if o.maxPerDest > 0 && bits.OnesCount(uint(mask&o.sameDest[i])) > o.maxPerDest {
+			return false
+		}
+
+		// Check hazmat compatibility
+		if order.IsHazmat {
+			hasHazmat = true
+		} else {
+			hasNonHazmat = true
+		}
+
+		// At most maxOrigins distinct origins and maxDestinations
+		// distinct destinations
+		if !containsFold(origins, order.Origin) {
+			origins = append(origins, order.Origin)
+			if len(origins) > o.maxOrigins {
+				return false
+			}
+		}
+		if !containsFold(destinations, order.Destination) {
+			destinations = append(destinations, order.Destination)
+			if len(destinations) > o.maxDestinations {
+				return false
+			}
+		}
+
+		if pickupDays != nil {
+			latestPickup = max(latestPickup, pickupDays[i])
+			earliestDelivery = min(earliestDelivery, deliveryDays[i])
+		}
+	}
+
+	// Hazmat can only be with hazmat, and only on a certified truck
+	if hasHazmat && (hasNonHazmat || !o.truck.allowsHazmat()) {
+		return false
+	}
+
+	// The shared trip must make every delivery date
+	if (o.truck.TransitDays > 0 || len(origins) > 1 || len(destinations) > 1) && int64(latestPickup)+o.truck.TransitDays > int64(earliestDelivery) {
+		return false
+	}
+
+	return o.axlesWithin(mask)
+}
+
+// FindOptimal finds the best subset using DP
+// Capacity constraints already checked during precompute via pruning
+func (o *Optimizer) FindOptimal() int {
+	bestMask := 0
+	bestScore := int64(0)
+	// The top score of any load, which may be zero or less, unlike
+	// bestScore, whose zero stands for the empty load
+	var topScore int64
+	hasTop := false
+
+	if o.timedOut {
+		return 0
+	}
+	if o.objective == objectiveMaximinMargin {
+		return o.findMaximin()
+	}
+	if o.objective == objectiveTargetUtilization {
+		return o.findTargetUtilization()
+	}
+
+	// Iterate through all subsets
+	for mask := 1; mask < o.maxMask; mask++ {
+		if o.expired(mask) {
+			return 0
+		}
+		if !o.valid[mask] || !o.complete(mask) {
+			continue
+		}
+		score := o.dpScore(mask)
+		// Track the best two distinct scores; a load tying the top is no
+		// runner-up, since the margin between them is nothing to report
+		if !hasTop || score > topScore {
+			if hasTop {
+				o.runnerUpScore, o.hasRunnerUp = topScore, true
+			}
+			topScore, hasTop = score, true
+		} else if score < topScore && (!o.hasRunnerUp || score > o.runnerUpScore) {
+			o.runnerUpScore, o.hasRunnerUp = score, true
+		}
+		if score > bestScore || score == bestScore && bestMask != 0 && o.moreUrgent(mask, bestMask) {
+			bestScore = score
+			bestMask = mask
+		}
+	}
+
+	return bestMask
+}
+
+// marginOverRunnerUp returns how far best's score is ahead of the next best
+// distinct score FindOptimal saw, or nil when no other load scored less
+func (o *Optimizer) marginOverRunnerUp(best int) *int64 {
+	if best == 0 || !o.hasRunnerUp {
+		return nil
+	}
+	margin := o.dpScore(best) - o.runnerUpScore
+	return &margin
+}
+
+// moreUrgent reports whether load a should win a score tie with load b
+// because an order in it is due sooner. It is always false unless the
+// request set tie_break_by_delivery, so ties otherwise keep the lower mask.
+func (o *Optimizer) moreUrgent(a, b int) bool {
+	if !o.tieBreakByDelivery {
+		return false
+	}
+	if o.deliveryDays == nil {
+		_, o.deliveryDays = o.orderDays()
+	}
+	return o.earliestDeliveryDay(a) < o.earliestDeliveryDay(b)
+}
+
+// earliestDeliveryDay returns the soonest delivery day number in mask
+func (o *Optimizer) earliestDeliveryDay(mask int) int32 {
+	day := int32(math.MaxInt32)
+	for m := uint(mask); m != 0; m &= m - 1 {
+		day = min(day, o.deliveryDays[bits.TrailingZeros(m)])
+	}
+	return day
+}
+
+// dpScore is score read from the DP tables, for masks precompute visited.
+// Co-load bonuses depend on pairs, not single orders, so they can't be
+// summed into the payout table and are added here.
+func (o *Optimizer) dpScore(mask int) int64 {
+	return o.payout[mask] + o.coLoadBonus(mask) + o.bonus(mask) - o.overagePenalty(o.weight[mask])
+}
+
+// groupMasks converts order_groups from IDs to bitmasks
+func groupMasks(orders []Order, groups [][]string) []int {
+	if len(groups) == 0 {
+		return nil
+	}
+	masks := make([]int, 0, len(groups))
+	for _, g := range groups {
+		masks = append(masks, idMask(orders, g))
+	}
+	return masks
+}
+
+// conflictMasks converts incompatible_pairs to a per-order bitmask of the
+// orders each one conflicts with, so a subset check is a single AND
+func conflictMasks(orders []Order, pairs [][]string) []int {
+	if len(pairs) == 0 {
+		return nil
+	}
+	conflicts := make([]int, len(orders))
+	for _, p := range pairs {
+		a, b := idMask(orders, p[:1]), idMask(orders, p[1:])
+		conflicts[bits.TrailingZeros(uint(a))] |= b
+		conflicts[bits.TrailingZeros(uint(b))] |= a
+	}
+	return conflicts
+}
+
+// idMask converts a list of order IDs to a bitmask. IDs are validated to
+// exist before solving.
+func idMask(orders []Order, ids []string) int {
+	if len(ids) == 0 {
+		return 0
+	}
+	index := make(map[string]int, len(orders))
+	for i, o := range orders {
+		index[o.ID] = i
+	}
+	m := 0
+	for _, id := range ids {
+		m |= 1 << index[id]
+	}
+	return m
+}
+
+// overagePenalty returns the penalty for a load weighing weight. Like the
+// preference bonus it only affects which load is chosen.
+func (o *Optimizer) overagePenalty(weight Quantity) int64 {
+	if weight <= o.truck.freeWeightLbs() {
+		return 0
+	}
+	return overageCents(weight-o.truck.freeWeightLbs(), o.truck.OveragePenaltyCentsPerLb)
+}
+
+// overageCents prices an overage at centsPerLb, charging a fraction of a
+// pound its share rounded up, so the penalty is never undercounted
+func overageCents(over Quantity, centsPerLb int64) int64 {
+	return (int64(over)*centsPerLb + quantityScale - 1) / quantityScale
+}
+
+// overageFits reports whether overageCents can price an overage of up to over
+// without wrapping around
+func overageFits(over Quantity, centsPerLb int64) bool {
+	if over <= 0 {
+		return true
+	}
+	hi, lo := bits.Mul64(uint64(over), uint64(centsPerLb))
+	return hi == 0 && lo <= math.MaxInt64-(quantityScale-1)
+}
+
+// coLoadBonus returns the co-load bonuses earned by a mask: one for each
+// bonus pair it holds both orders of
+func (o *Optimizer) coLoadBonus(mask int) int64 {
+	var total int64
+	for i, pair := range o.coLoadPairs {
+		if mask&pair == pair {
+			total += o.coLoadCents[i]
+		}
+	}
+	return total
+}
+
+// coLoadMasks converts co_load_bonuses to a bitmask of both orders per bonus
+func coLoadMasks(orders []Order, bonuses []CoLoadBonus) []int {
+	if len(bonuses) == 0 {
+		return nil
+	}
+	masks := make([]int, len(bonuses))
+	for i, b := range bonuses {
+		masks[i] = idMask(orders, []string{b.OrderA, b.OrderB})
+	}
+	return masks
+}
+
+func coLoadCents(bonuses []CoLoadBonus) []int64 {
+	if len(bonuses) == 0 {
+		return nil
+	}
+	cents := make([]int64, len(bonuses))
+	for i, b := range bonuses {
+		cents[i] = b.BonusCents
+	}
+	return cents
+}
+
+// bonus returns the preference bonus for the preferred orders in a mask.
+// It only steers which load is chosen and is never part of the payout.
+func (o *Optimizer) bonus(mask int) int64 {
+	return o.preferenceBonus * int64(bits.OnesCount(uint(mask&o.preferred)))
+}
+
+// groupsComplete reports whether the mask holds every order group either
+// entirely or not at all
+func (o *Optimizer) groupsComplete(mask int) bool {
+	for _, g := range o.groups {
+		if m := mask & g; m != 0 && m != g {
+			return false
+		}
+	}
+	return true
+}
+
+// BuildResponse creates the response from the best mask
+func (o *Optimizer) BuildResponse(bestMask int) *OptimizeResponse {
+	// Totals are summed from the orders rather than read from the DP tables
+	// so heuristic solvers that never built the tables can share this.
+	orderIDs := []string{}
+	var indices []int
+	infeasibleIDs := []string{}
+	var origins, destinations []string
+	var payout, corridorMiles int64
+	var weight, volume Quantity
+	for i := 0; i < o.n; i++ {
+		if o.orders[i].WeightLbs > o.weightCap || o.orders[i].VolumeCuft > o.volumeCap || o.overAxle(i) {
+			infeasibleIDs = append(infeasibleIDs, o.orders[i].ID)
+		}
+		if bestMask&(1<<i) != 0 {
+			orderIDs = append(orderIDs, o.orders[i].ID)
+			indices = append(indices, i)
+			if o.maxOrigins > 1 && !containsFold(origins, o.orders[i].Origin) {
+				origins = append(origins, o.orders[i].Origin)
+			}
+			if o.maxDestinations > 1 && !containsFold(destinations, o.orders[i].Destination) {
+				destinations = append(destinations, o.orders[i].Destination)
+			}
+			payout += o.orders[i].PayoutCents
+			weight = addSaturating(weight, o.orders[i].WeightLbs)
+			volume = addSaturating(volume, o.orders[i].VolumeCuft)
+			corridorMiles = max(corridorMiles, o.orders[i].DistanceMiles)
+		}
+	}
+
+	var co2 *int64
+	if o.truck.CO2GramsPerMile > 0 && corridorMiles > 0 {
+		grams := corridorMiles * o.truck.CO2GramsPerMile
+		co2 = &grams
+	}
+
+	coLoad := o.coLoadBonus(bestMask)
+	payout += coLoad
+
+	avgPayout := int64(0)
+	if len(orderIDs) > 0 {
+		avgPayout = payout / int64(len(orderIDs))
+	}
+
+	remainingWeight, weightPct := capacityUsage(weight, o.truck.freeWeightLbs(), o.ignoreWeight)
+	remainingVolume, volumePct := capacityUsage(volume, o.truck.freeVolumeCuft(), o.ignoreVolume)
+	var overage Quantity
+	if !o.ignoreWeight {
+		overage = max(0, weight-o.truck.freeWeightLbs())
+	}
+	var fixedCost int64
+	var minMargin *int64
+	if bestMask != 0 {
+		fixedCost = o.truck.FixedCostCents
+		if o.objective == objectiveMaximinMargin {
+			margin := o.minMargin(bestMask)
+			minMargin = &margin
+		}
+	}
+
+	return &OptimizeResponse{
+		TruckID:                  o.truck.ID,
+		SelectedOrderIDs:         orderIDs,
+		selectedIndices:          indices,
+		InfeasibleOrderIDs:       infeasibleIDs,
+		Origins:                  origins,
+		Destinations:             destinations,
+		TotalPayoutCents:         payout,
+		CoLoadBonusCents:         coLoad,
+		AvgPayoutPerOrderCents:   avgPayout,
+		TotalWeightLbs:           weight,
+		TotalVolumeCuft:          volume,
+		RemainingWeightLbs:       remainingWeight,
+		OverageLbs:               overage,
+		OveragePenaltyCents:      o.overagePenalty(weight),
+		FixedCostCents:           fixedCost,
+		RemainingVolumeCuft:      remainingVolume,
+		UtilizationWeightPercent: roundPercent(weightPct),
+		UtilizationVolumePercent: roundPercent(volumePct),
+		BindingConstraint:        o.bindingConstraint(bestMask, weightPct, volumePct),
+		RejectedOrders:           o.rejectedOrders(bestMask),
+		MinMarginCents:           minMargin,
+		Warnings:                 append(o.capacityWarnings(len(infeasibleIDs)), o.payoutWarnings()...),
+		EstimatedCO2Grams:        co2,
+	}
+}
+
+// BuildDispatch builds the response for a solver's chosen load. With a
+// fixed cost, a load that doesn't earn it back is swapped for the empty
+// plan: the truck is better off not leaving. Break-even loads stay home too.
+func (o *Optimizer) BuildDispatch(mask int) *OptimizeResponse {
+	if mask == 0 || o.truck.FixedCostCents == 0 || o.netPayout(mask) > 0 {
+		return o.BuildResponse(mask)
+	}
+	resp := o.BuildResponse(0)
+	resp.NotWorthDispatching = true
+	return resp
+}
+
+// netPayout is what a load earns after the overage penalty and the fixed
+// cost. The preference bonus isn't money, so it doesn't count.
+func (o *Optimizer) netPayout(mask int) int64 {
+	payout, weight, _ := o.maskTotals(mask)
+	return payout + o.coLoadBonus(mask) - o.overagePenalty(weight) - o.truck.FixedCostCents
+}
+
+// capacityUsage returns the remaining capacity and utilization percentage for
+// one dimension. An ignored or unset dimension reports zero for both.
+func capacityUsage(used, capacity Quantity, ignored bool) (remaining Quantity, percent float64) {
+	if ignored || capacity <= 0 {
+		return 0, 0
+	}
+	return capacity - used, float64(used) / float64(capacity) * 100
+}
+
+// capacityWarnings flags a truck that can't carry any of the orders on its
+// own, which usually means the capacity or units were entered wrong
+func (o *Optimizer) capacityWarnings(infeasible int) []string {
+	if o.n == 0 || infeasible < o.n {
+		return nil
+	}
+	tooHeavy, tooBulky := true, true
+	for _, order := range o.orders {
+		if order.WeightLbs <= o.weightCap {
+			tooHeavy = false
+		}
+		if order.VolumeCuft <= o.volumeCap {
+			tooBulky = false
+		}
+	}
+
+	var warnings []string
+	if tooHeavy {
+		warnings = append(warnings, "no single order fits this truck's weight")
+	}
+	if tooBulky {
+		warnings = append(warnings, "no single order fits this truck's volume")
+	}
+	if !tooHeavy && !tooBulky {
+		warnings = append(warnings, "no single order fits this truck's weight and volume at once")
+	}
+	return warnings
+}
+
+// minOutlierOrders is how many weighed orders payoutWarnings needs for a
+// meaningful median
+const minOutlierOrders = 3
+
+// payoutWarnings flags orders whose payout per pound is more than
+// PAYOUT_OUTLIER_FACTOR times the median of the request's orders, or less
+// than the median divided by it, which usually means a payout entered in
+// dollars instead of cents or the other way round. Orders without weight
+// are skipped. Off when the factor is 0.
+func (o *Optimizer) payoutWarnings() []string {
+	factor := float64(cfg.PayoutOutlierFactor)
+	if factor == 0 {
+		return nil
+	}
+	var densities []float64
+	for _, order := range o.orders {
+		if order.WeightLbs > 0 {
+			densities = append(densities, float64(order.PayoutCents)/float64(order.WeightLbs))
+		}
+	}
+	if len(densities) < minOutlierOrders {
+		return nil
+	}
+	slices.Sort(densities)
+	median := densities[len(densities)/2]
+	if len(densities)%2 == 0 {
+		median = (densities[len(densities)/2-1] + median) / 2
+	}
+	if median == 0 {
+		return nil
+	}
+
+	var warnings []string
+	for _, order := range o.orders {
+		if order.WeightLbs == 0 {
+			continue
+		}
+		ratio := float64(order.PayoutCents) / float64(order.WeightLbs) / median
+		if ratio > factor {
+			warnings = append(warnings, fmt.Sprintf("order %s pays %.0fx the median payout per pound; check payout_cents", order.ID, ratio))
+		} else if ratio < 1/factor {
+			warnings = append(warnings, fmt.Sprintf("order %s pays under 1/%.0f of the median payout per pound; check payout_cents", order.ID, factor))
+		}
+	}
+	return warnings
+}
+
+// Binding constraint values
+const (
+	bindingWeight = "weight"
+	bindingVolume = "volume"
+	bindingNone   = "none"
+)
+
+// bindingConstraint reports which capacity stops the load from growing. A
+// dimension binds when some unselected order that fits the empty truck no
+// longer fits the remaining space in it. If both bind, the fuller one wins.
+// Orders left out only for compatibility or payout reasons bind nothing.
+func (o *Optimizer) bindingConstraint(mask int, weightPct, volumePct float64) string {
+	_, weight, volume := o.maskTotals(mask)
+	var weightBound, volumeBound bool
+	for i := 0; i < o.n; i++ {
+		order := o.orders[i]
+		if mask&(1<<i) != 0 || order.WeightLbs > o.weightCap || order.VolumeCuft > o.volumeCap {
+			continue
+		}
+		if weight+order.WeightLbs > o.weightCap {
+			weightBound = true
+		}
+		if volume+order.VolumeCuft > o.volumeCap {
+			volumeBound = true
+		}
+	}
+
+	switch {
+	case weightBound && volumeBound:
+		if volumePct > weightPct {
+			return bindingVolume
+		}
+		return bindingWeight
+	case weightBound:
+		return bindingWeight
+	case volumeBound:
+		return bindingVolume
+	}
+	return bindingNone
+}
+
+// diagnostics summarizes the work done by precompute
+func (o *Optimizer) diagnostics() *Diagnostics {
+	total := int64(o.maxMask - 1)
+	visited := int64(o.visited)
+	return &Diagnostics{
+		SubsetsTotal:          total,
+		SubsetsVisited:        visited,
+		SubsetsSkippedPercent: roundTo2Decimals(float64(total-visited) / float64(total) * 100),
+	}
+}
+
+// expired reports whether the deadline has passed. The clock is only
+// sampled every 4096 masks to keep the check cheap in the hot loop.
+func (o *Optimizer) expired(mask int) bool {
+	if o.deadline.IsZero() || mask&4095 != 0 {
+		return false
+	}
+	if time.Now().After(o.deadline) {
+		o.timedOut = true
+	}
+	return o.timedOut
+}
+
+// containsFold reports whether list holds s under stringsEqualFold
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if stringsEqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringsEqualFold(a, b string) bool {
+	return strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b))
+}
+
+func roundTo2Decimals(x float64) float64 {
+	return float64(int64(x*100+0.5)) / 100
+}
+
+// roundPercent rounds a utilization percentage to 2 decimals the way
+// ROUNDING_MODE says. Hundredths are first rounded to 4 decimals so float
+// error can't move a value off a .xx5 boundary or just below a whole
+// hundredth: 1.005*100 is 100.4999... in floating point.
+func roundPercent(x float64) float64 {
+	cents := math.Round(x*1e6) / 1e4
+	switch cfg.RoundingMode {
+	case roundingHalfEven:
+		return math.RoundToEven(cents) / 100
+	case roundingFloor:
+		return math.Floor(cents) / 100
+	}
+	return math.Floor(cents+0.5) / 100
 }
@@ -0,0 +1,1070 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testOrder returns a valid order on the shared test corridor
+func testOrder(id string, payout, weight, volume int64) Order {
+	return Order{
+		ID:           id,
+		PayoutCents:  payout,
+		WeightLbs:    wholeQuantity(weight),
+		VolumeCuft:   wholeQuantity(volume),
+		Origin:       "Los Angeles, CA",
+		Destination:  "Dallas, TX",
+		PickupDate:   "2025-12-05",
+		DeliveryDate: "2025-12-09",
+	}
+}
+
+// testRequest returns a request for a 44000 lb, 3000 cuft truck
+func testRequest(orders ...Order) *OptimizeRequest {
+	return &OptimizeRequest{
+		Truck:  Truck{ID: "truck-1", MaxWeightLbs: wholeQuantity(44000), MaxVolumeCuft: wholeQuantity(3000)},
+		Orders: orders,
+	}
+}
+
+// mustSolve validates and solves req, bypassing the cache
+func mustSolve(t testing.TB, req *OptimizeRequest) *OptimizeResponse {
+	t.Helper()
+	if err := validateRequest(req); err != nil {
+		t.Fatalf("validateRequest: %v", err)
+	}
+	resp, err := solve(context.Background(), req, 0)
+	if err != nil {
+		t.Fatalf("solve: %v", err)
+	}
+	return resp
+}
+
+// benchRequest returns n orders that mostly fit together, so the DP visits
+// a large share of the 2^n subsets
+func benchRequest(n int) *OptimizeRequest {
+	orders := make([]Order, n)
+	for i := range orders {
+		orders[i] = testOrder(fmt.Sprintf("ord-%02d", i), int64(1000+i*37%500), int64(1000+i*53%900), int64(50+i*11%80))
+		orders[i].IsHazmat = i%7 == 6
+	}
+	req := testRequest(orders...)
+	if err := validateRequest(req); err != nil {
+		panic(err)
+	}
+	return req
+}
+
+func BenchmarkSolve(b *testing.B) {
+	for _, n := range []int{12, 16, 20} {
+		req := benchRequest(n)
+		b.Run(fmt.Sprintf("orders=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := solve(context.Background(), req, 0); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkIsValidSubset checks every mask the way precompute did before it
+// carried hazmat and route state through the DP, for comparison with
+// BenchmarkSolve
+func BenchmarkIsValidSubset(b *testing.B) {
+	for _, n := range []int{12, 16, 20} {
+		o := baseOptimizer(toInternalUnits(benchRequest(n)))
+		b.Run(fmt.Sprintf("orders=%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for mask := 1; mask < 1<<n; mask++ {
+					o.isValidSubset(mask)
+				}
+			}
+		})
+	}
+}
+
+// randomOrders returns n orders drawn from a few places and dates, some of
+// them hazmat, so every compatibility rule gets exercised
+func randomOrders(r *rand.Rand, n int) []Order {
+	places := []string{"Los Angeles, CA", "Dallas, TX", "Phoenix, AZ"}
+	orders := make([]Order, n)
+	for i := range orders {
+		orders[i] = testOrder(fmt.Sprintf("ord-%02d", i), r.Int64N(5000), 1000+r.Int64N(15000), 100+r.Int64N(1000))
+		orders[i].Origin = places[r.IntN(2)]
+		orders[i].Destination = places[1+r.IntN(2)]
+		orders[i].PickupDate = fmt.Sprintf("2025-12-%02d", 1+r.IntN(4))
+		orders[i].DeliveryDate = fmt.Sprintf("2025-12-%02d", 5+r.IntN(4))
+		orders[i].IsHazmat = r.IntN(5) == 0
+	}
+	return orders
+}
+
+// constraintCases switch on the constraints the solvers check, for
+// requests built from randomOrders
+var constraintCases = []struct {
+	name   string
+	modify func(req *OptimizeRequest)
+}{
+	{"plain", func(req *OptimizeRequest) {}},
+	{"multi-stop", func(req *OptimizeRequest) {
+		req.AllowMultiOrigin, req.AllowMultiDestination = true, true
+		req.Truck.TransitDays = 2
+	}},
+	{"multi-drop with per-destination cap", func(req *OptimizeRequest) {
+		req.AllowMultiDestination = true
+		req.MaxOrdersPerDestination = 2
+	}},
+	{"incompatible pairs and groups", func(req *OptimizeRequest) {
+		req.AllowMultiOrigin, req.AllowMultiDestination = true, true
+		req.IncompatiblePairs = [][]string{{"ord-00", "ord-01"}, {"ord-02", "ord-05"}}
+		req.OrderGroups = [][]string{{"ord-03", "ord-04"}}
+	}},
+	{"axles and hazmat truck", func(req *OptimizeRequest) {
+		req.Truck.AxleCapacities = []Quantity{wholeQuantity(12000), wholeQuantity(20000)}
+		for i := range req.Orders {
+			req.Orders[i].AxlePosition = i % 2
+		}
+		allowed := false
+		req.Truck.HazmatAllowed = &allowed
+	}},
+	{"co-load and preference bonuses", func(req *OptimizeRequest) {
+		req.CoLoadBonuses = []CoLoadBonus{{OrderA: "ord-01", OrderB: "ord-08", BonusCents: 4000}, {OrderA: "ord-02", OrderB: "ord-03", BonusCents: 1500}}
+		req.PreferredOrderIDs = []string{"ord-06"}
+		req.PreferenceBonusCents = 2500
+	}},
+	{"category cap", func(req *OptimizeRequest) {
+		for i := range req.Orders {
+			req.Orders[i].Category = []string{"dry", "frozen", "", "dry"}[i%4]
+		}
+		req.MaxCategoryPercent = 60
+	}},
+}
+
+func TestExactMatchesBruteForce(t *testing.T) {
+	for _, tt := range constraintCases {
+		t.Run(tt.name, func(t *testing.T) {
+			loaded := 0
+			for seed := uint64(1); seed <= 20; seed++ {
+				req := testRequest(randomOrders(rand.New(rand.NewPCG(seed, 0)), 10)...)
+				tt.modify(req)
+				if err := validateRequest(req); err != nil {
+					t.Fatalf("seed %d: validateRequest: %v", seed, err)
+				}
+				opt, err := newOptimizer(toInternalUnits(req), time.Time{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				bestMask := opt.FindOptimal()
+
+				var want int64
+				for mask := 1; mask < opt.maxMask; mask++ {
+					valid := opt.fits(mask) && opt.isValidSubset(mask)
+					if opt.valid[mask] != valid {
+						t.Fatalf("seed %d: mask %b: DP valid %t, isValidSubset %t", seed, mask, opt.valid[mask], valid)
+					}
+					if valid && opt.complete(mask) {
+						want = max(want, opt.score(mask))
+					}
+				}
+				if got := opt.score(bestMask); got != want {
+					t.Errorf("seed %d: FindOptimal scored %d, brute force %d", seed, got, want)
+				}
+				if bits.OnesCount(uint(bestMask)) > 1 {
+					loaded++
+				}
+			}
+			if loaded == 0 {
+				t.Error("no seed produced a multi-order load; the cases test nothing")
+			}
+		})
+	}
+}
+
+func TestSolveEmptyOrders(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(req *OptimizeRequest)
+	}{
+		{"plain", func(req *OptimizeRequest) {}},
+		{"ignore weight", func(req *OptimizeRequest) {
+			req.IgnoreWeight = true
+			req.Truck.MaxWeightLbs = 0
+		}},
+		{"preloaded", func(req *OptimizeRequest) { req.Truck.PreloadedWeightLbs = wholeQuantity(4000) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest()
+			tt.modify(req)
+			resp := mustSolve(t, req)
+
+			if resp.SelectedOrderIDs == nil || len(resp.SelectedOrderIDs) != 0 {
+				t.Errorf("selected_order_ids = %#v, want an empty list", resp.SelectedOrderIDs)
+			}
+			if resp.TotalPayoutCents != 0 || resp.TotalWeightLbs != 0 || resp.TotalVolumeCuft != 0 {
+				t.Errorf("totals = %d, %s, %s, want zero", resp.TotalPayoutCents, resp.TotalWeightLbs, resp.TotalVolumeCuft)
+			}
+			if resp.UtilizationWeightPercent != 0 || resp.UtilizationVolumePercent != 0 {
+				t.Errorf("utilization = %v, %v, want 0", resp.UtilizationWeightPercent, resp.UtilizationVolumePercent)
+			}
+			if !resp.Optimal {
+				t.Error("an empty plan for no orders should be optimal")
+			}
+			body, err := json.Marshal(resp)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if !strings.Contains(string(body), `"selected_order_ids":[]`) {
+				t.Errorf("JSON has no empty selected_order_ids: %s", body)
+			}
+		})
+	}
+}
+
+func TestIgnoreDimension(t *testing.T) {
+	// Every order alone overflows the ignored dimension; all four together
+	// just fit the other one
+	tests := []struct {
+		name   string
+		ignore func(req *OptimizeRequest)
+		weight int64
+		volume int64
+	}{
+		{"ignore volume", func(req *OptimizeRequest) { req.IgnoreVolume = true }, 11000, 5000},
+		{"ignore weight", func(req *OptimizeRequest) { req.IgnoreWeight = true }, 50000, 750},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var orders []Order
+			for _, id := range []string{"a", "b", "c", "d"} {
+				orders = append(orders, testOrder(id, 1000, tt.weight, tt.volume))
+			}
+			req := testRequest(orders...)
+			tt.ignore(req)
+			if err := validateRequest(req); err != nil {
+				t.Fatalf("validateRequest: %v", err)
+			}
+			opt, err := newOptimizer(toInternalUnits(req), time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for mask := 0; mask < opt.maxMask; mask++ {
+				if !opt.valid[mask] {
+					t.Errorf("mask %b pruned", mask)
+				}
+			}
+
+			resp := mustSolve(t, req)
+			if len(resp.SelectedOrderIDs) != 4 {
+				t.Errorf("selected %v, want all four orders", resp.SelectedOrderIDs)
+			}
+			if req.IgnoreVolume && resp.UtilizationVolumePercent != 0 || req.IgnoreWeight && resp.UtilizationWeightPercent != 0 {
+				t.Errorf("utilization = %v, %v, want 0 for the ignored dimension", resp.UtilizationWeightPercent, resp.UtilizationVolumePercent)
+			}
+		})
+	}
+}
+
+func TestOrderGroups(t *testing.T) {
+	// Unconstrained, x plus two of the group's orders is best (4500). Kept
+	// together, the group fits but x doesn't fit beside it.
+	orders := func() []Order {
+		return []Order{
+			testOrder("g1", 1000, 10000, 100),
+			testOrder("g2", 1000, 10000, 100),
+			testOrder("g3", 1000, 10000, 100),
+			testOrder("x", 2500, 20000, 100),
+		}
+	}
+	tests := []struct {
+		name   string
+		groups [][]string
+		heavy  bool // make the group too heavy to ship at all
+		want   []string
+	}{
+		{"no group", nil, false, []string{"g1", "g2", "x"}},
+		{"group fits", [][]string{{"g1", "g2", "g3"}}, false, []string{"g1", "g2", "g3"}},
+		{"group too heavy", [][]string{{"g1", "g2", "g3"}}, true, []string{"x"}},
+	}
+	for _, tt := range tests {
+		for _, solver := range []string{solverExact, solverGreedy} {
+			if solver == solverGreedy && tt.groups == nil {
+				// Greedy isn't optimal there; the group cases are what it
+				// must get right
+				continue
+			}
+			t.Run(tt.name+"/"+solver, func(t *testing.T) {
+				req := testRequest(orders()...)
+				if tt.heavy {
+					for i := 0; i < 3; i++ {
+						req.Orders[i].WeightLbs = wholeQuantity(15000)
+					}
+				}
+				req.OrderGroups = tt.groups
+				req.Solver = solver
+				if resp := mustSolve(t, req); !slices.Equal(resp.SelectedOrderIDs, tt.want) {
+					t.Errorf("selected %v, want %v", resp.SelectedOrderIDs, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestIncompatiblePairs(t *testing.T) {
+	// Unconstrained, a and b are the best load (6000); forbidding the pair
+	// leaves a and c (5000)
+	tests := []struct {
+		name  string
+		pairs [][]string
+		want  []string
+	}{
+		{"no pairs", nil, []string{"a", "b"}},
+		{"a and b incompatible", [][]string{{"b", "a"}}, []string{"a", "c"}},
+	}
+	for _, tt := range tests {
+		for _, solver := range []string{solverExact, solverGreedy} {
+			t.Run(tt.name+"/"+solver, func(t *testing.T) {
+				req := testRequest(
+					testOrder("a", 3000, 20000, 100),
+					testOrder("b", 3000, 20000, 100),
+					testOrder("c", 2000, 20000, 100),
+				)
+				req.IncompatiblePairs = tt.pairs
+				req.Solver = solver
+				if resp := mustSolve(t, req); !slices.Equal(resp.SelectedOrderIDs, tt.want) {
+					t.Errorf("selected %v, want %v", resp.SelectedOrderIDs, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestMaxOrdersPerDestination(t *testing.T) {
+	// Three Dallas drops and one Phoenix drop all fit; a cap of two leaves
+	// out the lowest-paying Dallas order
+	orders := []Order{
+		testOrder("d1", 3000, 5000, 100),
+		testOrder("d2", 1000, 5000, 100),
+		testOrder("d3", 2000, 5000, 100),
+		testOrder("p1", 1500, 5000, 100),
+	}
+	orders[3].Destination = "Phoenix, AZ"
+	tests := []struct {
+		name string
+		cap  int
+		want []string
+	}{
+		{"no cap", 0, []string{"d1", "d2", "d3", "p1"}},
+		{"cap of two", 2, []string{"d1", "d3", "p1"}},
+	}
+	for _, tt := range tests {
+		for _, solver := range []string{solverExact, solverGreedy} {
+			t.Run(tt.name+"/"+solver, func(t *testing.T) {
+				req := testRequest(slices.Clone(orders)...)
+				req.AllowMultiDestination = true
+				req.MaxOrdersPerDestination = tt.cap
+				req.Solver = solver
+				if resp := mustSolve(t, req); !slices.Equal(resp.SelectedOrderIDs, tt.want) {
+					t.Errorf("selected %v, want %v", resp.SelectedOrderIDs, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestFixedCostBreakEven(t *testing.T) {
+	// The best load pays 5000 in total
+	tests := []struct {
+		fixedCost int64
+		dispatch  bool
+	}{
+		{0, true},
+		{4999, true},
+		{5000, false}, // breaking even isn't worth the trip
+		{5001, false},
+	}
+	for _, tt := range tests {
+		for _, solver := range []string{solverExact, solverGreedy} {
+			t.Run(fmt.Sprintf("%d/%s", tt.fixedCost, solver), func(t *testing.T) {
+				req := testRequest(testOrder("a", 3000, 10000, 100), testOrder("b", 2000, 10000, 100))
+				req.Truck.FixedCostCents = tt.fixedCost
+				req.Solver = solver
+				resp := mustSolve(t, req)
+				if tt.dispatch {
+					if resp.NotWorthDispatching || len(resp.SelectedOrderIDs) != 2 || resp.FixedCostCents != tt.fixedCost {
+						t.Errorf("selected %v, not_worth_dispatching %t, fixed_cost_cents %d; want both orders dispatched", resp.SelectedOrderIDs, resp.NotWorthDispatching, resp.FixedCostCents)
+					}
+					return
+				}
+				if !resp.NotWorthDispatching || len(resp.SelectedOrderIDs) != 0 || resp.TotalPayoutCents != 0 || resp.FixedCostCents != 0 {
+					t.Errorf("selected %v, not_worth_dispatching %t, total_payout_cents %d, fixed_cost_cents %d; want an empty plan", resp.SelectedOrderIDs, resp.NotWorthDispatching, resp.TotalPayoutCents, resp.FixedCostCents)
+				}
+			})
+		}
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(req *OptimizeRequest)
+		wantErr string
+	}{
+		{"valid", func(req *OptimizeRequest) {}, ""},
+		{"duplicate order id", func(req *OptimizeRequest) { req.Orders[2].ID = "a" }, `orders[2].id "a" is already used by orders[0]`},
+		{"group names unknown order", func(req *OptimizeRequest) { req.OrderGroups = [][]string{{"a", "zz"}} }, "unknown order id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(testOrder("a", 100, 100, 10), testOrder("b", 100, 100, 10), testOrder("c", 100, 100, 10))
+			tt.modify(req)
+			err := validateRequest(req)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want one containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestHazmatAllowed(t *testing.T) {
+	yes, no := true, false
+	tests := []struct {
+		name    string
+		allowed *bool
+		want    []string
+	}{
+		{"omitted", nil, []string{"h1", "h2"}},
+		{"allowed", &yes, []string{"h1", "h2"}},
+		{"not certified", &no, []string{"n"}},
+	}
+	for _, tt := range tests {
+		for _, solver := range []string{solverExact, solverGreedy} {
+			t.Run(tt.name+"/"+solver, func(t *testing.T) {
+				// The hazmat orders pay more together than the other order
+				h1, h2 := testOrder("h1", 3000, 1000, 100), testOrder("h2", 3000, 1000, 100)
+				h1.IsHazmat, h2.IsHazmat = true, true
+				req := testRequest(h1, h2, testOrder("n", 2000, 1000, 100))
+				req.Truck.HazmatAllowed = tt.allowed
+				req.Solver = solver
+				if resp := mustSolve(t, req); !slices.Equal(resp.SelectedOrderIDs, tt.want) {
+					t.Errorf("selected %v, want %v", resp.SelectedOrderIDs, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	seed := int64(7)
+	tests := []struct {
+		name   string
+		modify func(req *OptimizeRequest)
+		same   bool
+	}{
+		{"identical", func(req *OptimizeRequest) {}, true},
+		{"greedy_sort", func(req *OptimizeRequest) { req.GreedySort = greedySortPayout }, true},
+		{"seed", func(req *OptimizeRequest) { req.Seed = &seed }, true},
+		{"payout", func(req *OptimizeRequest) { req.Orders[0].PayoutCents++ }, false},
+		{"pricing_snapshot_id", func(req *OptimizeRequest) { req.PricingSnapshotID = "snap-2" }, false},
+		{"solver", func(req *OptimizeRequest) { req.Solver = solverGreedy }, false},
+		{"X-Max-Orders", func(req *OptimizeRequest) { req.maxOrders = 3 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := testRequest(testOrder("a", 1000, 1000, 100), testOrder("b", 2000, 1000, 100))
+			other := testRequest(testOrder("a", 1000, 1000, 100), testOrder("b", 2000, 1000, 100))
+			tt.modify(other)
+			baseKey, err := cacheKey(base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			otherKey, err := cacheKey(other)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (baseKey == otherKey) != tt.same {
+				t.Errorf("keys equal = %t, want %t", baseKey == otherKey, tt.same)
+			}
+		})
+	}
+}
+
+// TestCacheKeyFieldsInSync fails when a request field is added without
+// deciding whether it belongs in the cache key
+func TestCacheKeyFieldsInSync(t *testing.T) {
+	// Fields that can't change a cached (optimal) answer
+	ignored := map[string]bool{"greedy_sort": true, "seed": true}
+
+	keyed := map[string]bool{}
+	for _, f := range reflect.VisibleFields(reflect.TypeFor[cacheKeyFields]()) {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		keyed[name] = true
+	}
+	for _, f := range reflect.VisibleFields(reflect.TypeFor[OptimizeRequest]()) {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if !keyed[name] && !ignored[name] {
+			t.Errorf("request field %s is neither in cacheKeyFields nor known not to affect the answer", name)
+		}
+	}
+}
+
+func TestMaxOrdersHeader(t *testing.T) {
+	savedKey, savedSubsets := cfg.AdminAPIKey, cfg.MaxSubsets
+	defer func() { cfg.AdminAPIKey, cfg.MaxSubsets = savedKey, savedSubsets }()
+	defer globalCache.clear()
+	// Three orders need 2^3 subsets, more than MAX_SUBSETS allows here
+	cfg.MaxSubsets = 1 << 2
+
+	body, err := json.Marshal(testRequest(testOrder("a", 1000, 1000, 100), testOrder("b", 1000, 1000, 100), testOrder("c", 1000, 1000, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		adminKey string
+		apiKey   string
+		header   string
+		wantCode int
+	}{
+		{"no header", "secret", "", "", http.StatusServiceUnavailable},
+		{"admin key not configured", "", "secret", "3", http.StatusForbidden},
+		{"no api key", "secret", "", "3", http.StatusUnauthorized},
+		{"wrong api key", "secret", "guess", "3", http.StatusUnauthorized},
+		{"over the hard ceiling", "secret", "secret", "27", http.StatusUnprocessableEntity},
+		{"below the order count", "secret", "secret", "2", http.StatusUnprocessableEntity},
+		{"admin raises the limit", "secret", "secret", "3", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.AdminAPIKey = tt.adminKey
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/optimize", bytes.NewReader(body))
+			r.Header.Set("Content-Type", contentTypeJSON)
+			if tt.apiKey != "" {
+				r.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.header != "" {
+				r.Header.Set("X-Max-Orders", tt.header)
+			}
+			w := httptest.NewRecorder()
+			optimizeHandler(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+}
+
+func TestMaxOrdersOverrideCap(t *testing.T) {
+	orders := make([]Order, maxOrders+1)
+	for i := range orders {
+		orders[i] = testOrder(fmt.Sprintf("ord-%02d", i), 1000, 1000, 100)
+	}
+	if err := validateRequest(testRequest(orders...)); err == nil {
+		t.Errorf("%d orders without X-Max-Orders: want an error", len(orders))
+	}
+	req := testRequest(orders...)
+	req.maxOrders = len(orders)
+	if err := validateRequest(req); err != nil {
+		t.Errorf("%d orders with X-Max-Orders %d: %v", len(orders), req.maxOrders, err)
+	}
+	if got := solverPath(len(orders), len(orders)); got != estimatePathExact {
+		t.Errorf("path at the override = %s, want %s", got, estimatePathExact)
+	}
+	// Below the order count the override rejects rather than falling back
+	// to a weaker solver
+	if got := solverPath(len(orders), 4); got != estimatePathRejected {
+		t.Errorf("path over the override = %s, want %s", got, estimatePathRejected)
+	}
+}
+
+func TestContentEncoding(t *testing.T) {
+	saved := cfg.MaxBodyBytes
+	defer func() { cfg.MaxBodyBytes = saved }()
+	cfg.MaxBodyBytes = 4096
+
+	body, err := json.Marshal(testRequest(testOrder("a", 1000, 1000, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gzipped := func(b []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(b)
+		zw.Close()
+		return buf.Bytes()
+	}
+	// Well under the limit on the wire, far over it once inflated
+	bomb := gzipped(append([]byte(`{"truck":`), bytes.Repeat([]byte(" "), 1<<20)...))
+	if int64(len(bomb)) >= cfg.MaxBodyBytes {
+		t.Fatalf("bomb is %d bytes compressed, want under the limit", len(bomb))
+	}
+
+	tests := []struct {
+		name     string
+		encoding string
+		body     []byte
+		wantCode int
+	}{
+		{"identity", "", body, http.StatusOK},
+		{"explicit identity", "identity", body, http.StatusOK},
+		{"gzip", "gzip", gzipped(body), http.StatusOK},
+		{"gzip over the limit once inflated", "gzip", bomb, http.StatusRequestEntityTooLarge},
+		{"not gzip", "gzip", body, http.StatusBadRequest},
+		{"unsupported encoding", "br", body, http.StatusUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/optimize", bytes.NewReader(tt.body))
+			r.Header.Set("Content-Type", contentTypeJSON)
+			if tt.encoding != "" {
+				r.Header.Set("Content-Encoding", tt.encoding)
+			}
+			w := httptest.NewRecorder()
+			optimizeHandler(w, r)
+			if w.Code != tt.wantCode {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+		})
+	}
+}
+
+func TestRejectedOrders(t *testing.T) {
+	// a pays more than b, so b is the order left off in each case
+	withPairs := func(req *OptimizeRequest) { req.IncompatiblePairs = [][]string{{"a", "b"}} }
+	withPerDest := func(req *OptimizeRequest) { req.MaxOrdersPerDestination = 1 }
+	withAxles := func(req *OptimizeRequest) {
+		req.Truck.AxleCapacities = []Quantity{wholeQuantity(1500), wholeQuantity(42500)}
+	}
+	withHazmat := func(req *OptimizeRequest) { req.Orders[1].IsHazmat = true }
+	withSecondStop := func(req *OptimizeRequest) { req.Orders[1].Destination = "Houston, TX" }
+	withHeavy := func(req *OptimizeRequest) { req.Orders[1].WeightLbs = wholeQuantity(45000) }
+	// c balances a's produce, but not a's and b's together
+	withCategories := func(req *OptimizeRequest) {
+		req.MaxCategoryPercent = 60
+		req.Orders[0].Category = "produce"
+		req.Orders[1].Category = "produce"
+		req.Orders = append(req.Orders, testOrder("c", 500, 1000, 100))
+	}
+
+	tests := []struct {
+		name   string
+		setup  func(*OptimizeRequest)
+		bucket func(*RejectedOrders) []string
+		// Selected orders when not just a
+		selected []string
+	}{
+		{"over weight", withHeavy, func(r *RejectedOrders) []string { return r.OverWeight }, nil},
+		{"hazmat", withHazmat, func(r *RejectedOrders) []string { return r.HazmatConflict }, nil},
+		{"incompatible pair", withPairs, func(r *RejectedOrders) []string { return r.RuleConflict }, nil},
+		{"category over its share", withCategories, func(r *RejectedOrders) []string { return r.RuleConflict }, []string{"a", "c"}},
+		{"second destination", withSecondStop, func(r *RejectedOrders) []string { return r.RouteMismatch }, nil},
+		{"max orders per destination", withPerDest, func(r *RejectedOrders) []string { return r.RouteMismatch }, nil},
+		{"axle group full", withAxles, func(r *RejectedOrders) []string { return r.NotOptimal }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(testOrder("a", 2000, 1000, 100), testOrder("b", 1000, 1000, 100))
+			tt.setup(req)
+			resp := mustSolve(t, req)
+			want := tt.selected
+			if want == nil {
+				want = []string{"a"}
+			}
+			if !slices.Equal(resp.SelectedOrderIDs, want) {
+				t.Fatalf("selected %v, want %v", resp.SelectedOrderIDs, want)
+			}
+			if resp.RejectedOrders == nil {
+				t.Fatal("no rejected_orders")
+			}
+			if got := tt.bucket(resp.RejectedOrders); !slices.Equal(got, []string{"b"}) {
+				t.Errorf("bucket holds %v, want [b]; rejected_orders %+v", got, *resp.RejectedOrders)
+			}
+		})
+	}
+}
+
+func TestRoundPercent(t *testing.T) {
+	saved := cfg.RoundingMode
+	defer func() { cfg.RoundingMode = saved }()
+
+	tests := []struct {
+		x                       float64
+		halfUp, halfEven, floor float64
+	}{
+		{12.345, 12.35, 12.34, 12.34},
+		{12.355, 12.36, 12.36, 12.35},
+		{1.005, 1.01, 1.0, 1.0}, // 1.005*100 is just under 100.5 in floating point
+		{99.995, 100, 100, 99.99},
+		{12.3449, 12.34, 12.34, 12.34},
+		{12.3451, 12.35, 12.35, 12.34},
+	}
+	for _, tt := range tests {
+		for mode, want := range map[string]float64{roundingHalfUp: tt.halfUp, roundingHalfEven: tt.halfEven, roundingFloor: tt.floor} {
+			cfg.RoundingMode = mode
+			if got := roundPercent(tt.x); got != want {
+				t.Errorf("%s: roundPercent(%v) = %v, want %v", mode, tt.x, got, want)
+			}
+		}
+	}
+
+	// A load at exactly 12.345% of the truck's weight reports it rounded
+	for mode, want := range map[string]float64{roundingHalfUp: 12.35, roundingHalfEven: 12.34, roundingFloor: 12.34} {
+		cfg.RoundingMode = mode
+		req := testRequest(testOrder("a", 1000, 4938, 100))
+		req.Truck.MaxWeightLbs = wholeQuantity(40000)
+		if got := mustSolve(t, req).UtilizationWeightPercent; got != want {
+			t.Errorf("%s: utilization_weight_percent = %v, want %v", mode, got, want)
+		}
+	}
+}
+
+func TestPreload(t *testing.T) {
+	// Empty, b and c pay most together; 10000 lbs of preload leaves room
+	// for a alone instead
+	orders := func() []Order {
+		return []Order{
+			testOrder("a", 3000, 30000, 100),
+			testOrder("b", 2000, 20000, 100),
+			testOrder("c", 1500, 20000, 100),
+		}
+	}
+	for _, tt := range []struct {
+		preload int64
+		want    []string
+	}{
+		{0, []string{"b", "c"}},
+		{10000, []string{"a"}},
+	} {
+		req := testRequest(orders()...)
+		req.Truck.PreloadedWeightLbs = wholeQuantity(tt.preload)
+		if resp := mustSolve(t, req); !slices.Equal(resp.SelectedOrderIDs, tt.want) {
+			t.Errorf("preload %d: selected %v, want %v", tt.preload, resp.SelectedOrderIDs, tt.want)
+		}
+	}
+
+	// Preloaded to the weight rating, only the overage allowance is left,
+	// which the greedy sort keys must survive
+	for _, solver := range []string{solverExact, solverGreedy} {
+		req := testRequest(testOrder("light", 500, 0, 100), testOrder("fits", 1000, 4000, 100), testOrder("heavy", 5000, 5000, 100))
+		req.Truck.PreloadedWeightLbs = req.Truck.MaxWeightLbs
+		req.Truck.WeightOveragePercent = 10
+		req.Solver = solver
+		resp := mustSolve(t, req)
+		if want := []string{"light", "fits"}; !slices.Equal(resp.SelectedOrderIDs, want) {
+			t.Errorf("%s: selected %v, want %v", solver, resp.SelectedOrderIDs, want)
+		}
+		if _, err := json.Marshal(resp); err != nil {
+			t.Errorf("%s: marshal: %v", solver, err)
+		}
+
+		o := baseOptimizer(toInternalUnits(req))
+		for i := range req.Orders {
+			if d, s := o.density(1<<i), o.size(1<<i); math.IsNaN(d) || math.IsNaN(s) {
+				t.Errorf("%s: order %s has density %v, size %v", solver, req.Orders[i].ID, d, s)
+			}
+		}
+	}
+}
+
+func TestMinTotalPayout(t *testing.T) {
+	// The best load pays 5000 in total
+	tests := []struct {
+		min      int64
+		dispatch bool
+	}{
+		{0, true},
+		{4999, true},
+		{5000, true}, // meeting the floor is enough
+		{5001, false},
+	}
+	for _, tt := range tests {
+		for _, solver := range []string{solverExact, solverGreedy} {
+			t.Run(fmt.Sprintf("%d/%s", tt.min, solver), func(t *testing.T) {
+				req := testRequest(testOrder("a", 3000, 10000, 100), testOrder("b", 2000, 10000, 100))
+				req.MinTotalPayoutCents = tt.min
+				req.Solver = solver
+				resp := mustSolve(t, req)
+				if tt.dispatch {
+					if resp.BelowMinPayout || resp.TotalPayoutCents != 5000 {
+						t.Errorf("total_payout_cents %d, below_min_payout %t; want the 5000 load", resp.TotalPayoutCents, resp.BelowMinPayout)
+					}
+					return
+				}
+				if !resp.BelowMinPayout || len(resp.SelectedOrderIDs) != 0 || resp.TotalPayoutCents != 0 {
+					t.Errorf("selected %v, below_min_payout %t, total_payout_cents %d; want an empty plan", resp.SelectedOrderIDs, resp.BelowMinPayout, resp.TotalPayoutCents)
+				}
+			})
+		}
+	}
+}
+
+func TestTieBreakByDelivery(t *testing.T) {
+	// Either order alone fills the truck and pays the same, but b is due
+	// three days sooner
+	a := testOrder("a", 3000, 30000, 100)
+	a.DeliveryDate = "2025-12-12"
+	b := testOrder("b", 3000, 30000, 100)
+	for _, solver := range []string{solverExact, solverMeetInMiddle} {
+		for _, tt := range []struct {
+			tieBreak bool
+			want     string
+		}{
+			{false, "a"}, // ties keep the lower mask
+			{true, "b"},
+		} {
+			t.Run(fmt.Sprintf("%s/%t", solver, tt.tieBreak), func(t *testing.T) {
+				req := testRequest(a, b)
+				req.Solver = solver
+				req.TieBreakByDelivery = tt.tieBreak
+				resp := mustSolve(t, req)
+				if !reflect.DeepEqual(resp.SelectedOrderIDs, []string{tt.want}) || resp.TotalPayoutCents != 3000 {
+					t.Errorf("selected %v paying %d, want [%s] paying 3000", resp.SelectedOrderIDs, resp.TotalPayoutCents, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestMarginOverRunnerUp(t *testing.T) {
+	// Overage is allowed up to 48400 lbs at a cent a pound
+	truck := testRequest().Truck
+	truck.WeightOveragePercent = 10
+	truck.OveragePenaltyCentsPerLb = 1
+	tests := []struct {
+		name   string
+		orders []Order
+		want   int64
+	}{
+		// The free order alone scores 0, ahead of nothing yet
+		{"zero-score runner-up first", []Order{testOrder("free", 0, 100, 10), testOrder("a", 1000, 1000, 10)}, 1000},
+		// Alone or with a, heavy scores 100 - 2000 or 1100 - 3000
+		{"negative runner-up", []Order{testOrder("heavy", 100, 46000, 10), testOrder("a", 1000, 1000, 10)}, 1000 + 1900},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(tt.orders...)
+			req.Truck = truck
+			resp := mustSolve(t, req)
+			if resp.MarginOverRunnerUpCents == nil || *resp.MarginOverRunnerUpCents != tt.want {
+				t.Errorf("margin_over_runner_up_cents %v, want %d", resp.MarginOverRunnerUpCents, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayoutOverflow(t *testing.T) {
+	const half = math.MaxInt64 / 2
+	tests := []struct {
+		name    string
+		modify  func(req *OptimizeRequest)
+		wantErr string
+	}{
+		{"sums to MaxInt64", func(req *OptimizeRequest) {}, ""},
+		{"one cent past", func(req *OptimizeRequest) { req.Orders[1].PayoutCents++ }, "sum of payout_cents exceeds"},
+		{"co-load bonus past", func(req *OptimizeRequest) {
+			req.CoLoadBonuses = []CoLoadBonus{{OrderA: "a", OrderB: "b", BonusCents: 1}}
+		}, "sum of payout_cents and co_load_bonuses exceeds"},
+		{"preference bonus past", func(req *OptimizeRequest) {
+			req.PreferredOrderIDs = []string{"a"}
+			req.PreferenceBonusCents = 1
+		}, "sum of payout_cents and bonuses exceeds"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// half + half + 1 is exactly MaxInt64
+			req := testRequest(testOrder("a", half, 1000, 100), testOrder("b", half+1, 1000, 100))
+			tt.modify(req)
+			err := validateRequest(req)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateRequest: %v", err)
+				}
+				if resp := mustSolve(t, req); resp.TotalPayoutCents != math.MaxInt64 {
+					t.Errorf("total_payout_cents %d, want %d", resp.TotalPayoutCents, int64(math.MaxInt64))
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateRequest: %v, want %q", err, tt.wantErr)
+			}
+
+			// The handler reports it as a validation failure
+			body, err := json.Marshal(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/optimize", bytes.NewReader(body))
+			r.Header.Set("Content-Type", contentTypeJSON)
+			w := httptest.NewRecorder()
+			optimizeHandler(w, r)
+			if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), errCodeValidation) {
+				t.Errorf("status %d, body %s; want 422 %s", w.Code, w.Body, errCodeValidation)
+			}
+		})
+	}
+
+	// A weight or volume just short of MaxInt64 plus a small order used to
+	// wrap around in the subset sums and pass as a light load
+	for _, dim := range []string{"weight", "volume"} {
+		for _, solver := range []string{solverExact, solverMeetInMiddle, solverGreedy} {
+			t.Run(dim+" "+solver, func(t *testing.T) {
+				// The DP only extends a subset with later orders, so the
+				// huge one goes last to be added to a load holding small
+				req := testRequest(testOrder("small", 100, 10, 10), testOrder("huge", 100000, 1000, 100))
+				if dim == "weight" {
+					req.Orders[1].WeightLbs = 9223372036854775000
+				} else {
+					req.Orders[1].VolumeCuft = 9223372036854775000
+				}
+				req.Solver = solver
+				resp := mustSolve(t, req)
+				if !slices.Equal(resp.SelectedOrderIDs, []string{"small"}) {
+					t.Errorf("selected %v, want [small]", resp.SelectedOrderIDs)
+				}
+			})
+		}
+	}
+}
+
+func TestOveragePenaltyOverflow(t *testing.T) {
+	// A 20% overage on 1000 lbs is at most 200 lbs, or 20000 hundredths
+	limit := int64((math.MaxInt64 - (quantityScale - 1)) / 20000)
+	tests := []struct {
+		name      string
+		maxWeight int64
+		penalty   int64
+		wantErr   bool
+	}{
+		{"at the limit", 1000, limit, false},
+		{"one cent past", 1000, limit + 1, true},
+		// 8800 lbs over at this rate used to wrap to a negative penalty
+		{"full-size truck", 44000, 30000000000000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(testOrder("a", 1000, tt.maxWeight*11/10, 100))
+			req.Truck.MaxWeightLbs = wholeQuantity(tt.maxWeight)
+			req.Truck.WeightOveragePercent = 20
+			req.Truck.OveragePenaltyCentsPerLb = tt.penalty
+			err := validateRequest(req)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "overage_penalty_cents_per_lb is too large") {
+					t.Errorf("validateRequest: %v, want overage_penalty_cents_per_lb is too large", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validateRequest: %v", err)
+			}
+			// The penalty dwarfs the payout, so the overweight order stays off
+			resp, err := solve(context.Background(), req, 0)
+			if err != nil {
+				t.Fatalf("solve: %v", err)
+			}
+			if len(resp.SelectedOrderIDs) != 0 {
+				t.Errorf("selected %v, want none", resp.SelectedOrderIDs)
+			}
+		})
+	}
+}
+
+func TestMaxCategoryPercent(t *testing.T) {
+	// Uncapped, the two dry orders pay the most; all three don't fit
+	orders := []Order{testOrder("a", 5000, 20000, 100), testOrder("b", 4000, 20000, 100), testOrder("c", 1000, 5000, 100)}
+	orders[0].Category, orders[1].Category, orders[2].Category = "dry", "dry", "frozen"
+	tests := []struct {
+		maxPercent float64
+		want       []string
+	}{
+		{0, []string{"a", "b"}},
+		{100, []string{"a", "b"}},
+		// a's 20000 of 25000 lbs is exactly 80%, and no load of one
+		// category is allowed
+		{80, []string{"a", "c"}},
+		{79, nil},
+	}
+	for _, tt := range tests {
+		for _, solver := range []string{solverExact, solverMeetInMiddle, solverGreedy} {
+			t.Run(fmt.Sprintf("%g/%s", tt.maxPercent, solver), func(t *testing.T) {
+				req := testRequest(orders...)
+				req.MaxCategoryPercent = tt.maxPercent
+				req.Solver = solver
+				resp := mustSolve(t, req)
+				if solver == solverGreedy {
+					// Greedy needn't find the best mix, only respect the cap
+					opt := baseOptimizer(toInternalUnits(req))
+					if mask := idMask(opt.orders, resp.SelectedOrderIDs); opt.overweightCategory(mask) != 0 {
+						t.Errorf("selected %v, over the category cap", resp.SelectedOrderIDs)
+					}
+					return
+				}
+				if !slices.Equal(resp.SelectedOrderIDs, tt.want) {
+					t.Errorf("selected %v, want %v", resp.SelectedOrderIDs, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestBestAddition(t *testing.T) {
+	tests := []struct {
+		name   string
+		orders []Order
+		modify func(req *OptimizeRequest)
+		want   *BestAddition
+	}{
+		{
+			// a beats the b-c group, which then needs 6000 lbs of room;
+			// b and c tie as the group's total, so b wins by request order
+			"group totals",
+			[]Order{testOrder("a", 5000, 30000, 100), testOrder("b", 2000, 10000, 100), testOrder("c", 2500, 10000, 100)},
+			func(req *OptimizeRequest) { req.OrderGroups = [][]string{{"b", "c"}} },
+			&BestAddition{OrderID: "b", OrderIDs: []string{"b", "c"}, PayoutCents: 4500, MakeRoomWeightLbs: wholeQuantity(6000)},
+		},
+		{
+			// b fits the truck but puts the front axle 5000 lbs over
+			"axle overage",
+			[]Order{testOrder("a", 5000, 15000, 100), testOrder("b", 3000, 10000, 100)},
+			func(req *OptimizeRequest) {
+				req.Truck.AxleCapacities = []Quantity{wholeQuantity(20000), wholeQuantity(30000)}
+			},
+			&BestAddition{OrderID: "b", OrderIDs: []string{"b"}, PayoutCents: 3000, MakeRoomAxleLbs: []Quantity{wholeQuantity(5000), 0}},
+		},
+		{
+			"incompatible pair",
+			[]Order{testOrder("a", 5000, 15000, 100), testOrder("b", 3000, 10000, 100)},
+			func(req *OptimizeRequest) { req.IncompatiblePairs = [][]string{{"a", "b"}} },
+			nil, // a rule keeps b out, and no room would change that
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := testRequest(tt.orders...)
+			tt.modify(req)
+			resp := mustSolve(t, req)
+			if !reflect.DeepEqual(resp.BestAddition, tt.want) {
+				t.Errorf("best_addition %+v, want %+v", resp.BestAddition, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"sort"
+	"time"
+)
+
+// maxMeetInMiddleOrders is the most orders the meet-in-the-middle solver
+// takes. Each half's DP tables then hold at most 2^20 subsets.
+const maxMeetInMiddleOrders = 40
+
+// halfLoad is a valid load drawn from one half of the orders
+type halfLoad struct {
+	mask           int // over all orders
+	weight, volume Quantity
+	// Payout plus the co-load and preference bonuses earned within the
+	// half; the load's score can't be higher
+	bound int64
+}
+
+// FindMeetInMiddle finds the load FindOptimal would under the default
+// objective without tables over all orders. It splits the orders in two,
+// lists each half's valid loads with the DP, and pairs them best bound
+// first, checking each pair with isValidSubset and complete. A pair scores
+// at most both halves' bounds plus every co-load bonus spanning the halves,
+// so the search stops once no remaining pair can beat the best found.
+// Validity is monotonic, so both halves of a valid load are valid and no
+// load is missed. Ties may go to a different load than FindOptimal's. The
+// solver gives up and sets timedOut, before allocating anything, when the
+// two halves' tables together exceed MAX_SUBSETS, and after MAX_SUBSETS
+// pair checks or once the deadline passes.
+func (o *Optimizer) FindMeetInMiddle() int {
+	if meetInMiddleSubsets(o.n) > cfg.MaxSubsets {
+		o.timedOut = true
+		return 0
+	}
+	split := o.n / 2
+	left := o.halfLoads(0, split)
+	right := o.halfLoads(split, o.n)
+	if o.timedOut {
+		return 0
+	}
+
+	var spanning int64
+	leftMask := 1<<split - 1
+	for k, pair := range o.coLoadPairs {
+		if pair&leftMask != 0 && pair&^leftMask != 0 {
+			spanning += o.coLoadCents[k]
+		}
+	}
+
+	bestMask := 0
+	bestScore := int64(0)
+	// pruned reports whether no pair bounded by bound can replace the best
+	// load; an equal score only can through tie_break_by_delivery
+	pruned := func(bound int64) bool {
+		return bound < bestScore || bound == bestScore && (bestMask == 0 || !o.tieBreakByDelivery)
+	}
+
+	var checked int64
+	for _, l := range left {
+		if pruned(l.bound + right[0].bound + spanning) {
+			break
+		}
+		for _, r := range right {
+			if pruned(l.bound + r.bound + spanning) {
+				break
+			}
+			checked++
+			if checked > cfg.MaxSubsets || o.expired(int(checked)) {
+				o.timedOut = true
+				return 0
+			}
+			if addSaturating(l.weight, r.weight) > o.weightCap || addSaturating(l.volume, r.volume) > o.volumeCap {
+				continue
+			}
+			mask := l.mask | r.mask
+			if !o.isValidSubset(mask) || !o.complete(mask) {
+				continue
+			}
+			score := o.score(mask)
+			if score > bestScore || score == bestScore && bestMask != 0 && o.moreUrgent(mask, bestMask) {
+				bestScore = score
+				bestMask = mask
+			}
+		}
+	}
+	return bestMask
+}
+
+// meetInMiddleSubsets is the number of subsets in both halves' DP tables
+// for n orders, which FindMeetInMiddle keeps within MAX_SUBSETS
+func meetInMiddleSubsets(n int) int64 {
+	return int64(1)<<(n/2) + int64(1)<<(n-n/2)
+}
+
+// halfLoads lists the valid loads of orders lo to hi-1, highest bound first.
+// The DP runs over those orders alone with the checks that every subset of
+// a valid load passes; groups and the category cap are left to complete.
+func (o *Optimizer) halfLoads(lo, hi int) []halfLoad {
+	half := *o
+	half.orders = o.orders[lo:hi]
+	half.n = hi - lo
+	within := func(masks []int) []int {
+		if masks == nil {
+			return nil
+		}
+		out := make([]int, len(masks))
+		for i, m := range masks {
+			out[i] = m >> lo & (1<<half.n - 1)
+		}
+		return out
+	}
+	if o.conflicts != nil {
+		half.conflicts = within(o.conflicts[lo:hi])
+	}
+	if o.sameDest != nil {
+		half.sameDest = within(o.sameDest[lo:hi])
+	}
+	half.axleMasks = within(o.axleMasks)
+	half.pickupDays, half.deliveryDays = nil, nil
+	half.buildTables()
+	if half.timedOut {
+		o.timedOut = true
+		return nil
+	}
+
+	var loads []halfLoad
+	for m := 0; m < half.maxMask; m++ {
+		if !half.valid[m] {
+			continue
+		}
+		mask := m << lo
+		loads = append(loads, halfLoad{
+			mask:   mask,
+			weight: half.weight[m],
+			volume: half.volume[m],
+			bound:  half.payout[m] + o.coLoadBonus(mask) + o.bonus(mask),
+		})
+	}
+	sort.SliceStable(loads, func(a, b int) bool { return loads[a].bound > loads[b].bound })
+	return loads
+}
+
+// meetInMiddleResponse solves internal with FindMeetInMiddle, or returns nil
+// when the solver gave up
+func meetInMiddleResponse(internal *OptimizeRequest, until time.Time) *OptimizeResponse {
+	opt := baseOptimizer(internal)
+	opt.deadline = until
+	bestMask := opt.FindMeetInMiddle()
+	if opt.timedOut {
+		return nil
+	}
+	resp := withMinPayout(withBaseline(opt.BuildDispatch(bestMask), internal), internal)
+	if !resp.UsedBaseline {
+		resp.Optimal = true
+	}
+	return resp
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"testing"
+	"time"
+)
+
+func TestMeetInMiddleMatchesExact(t *testing.T) {
+	for _, tt := range constraintCases {
+		t.Run(tt.name, func(t *testing.T) {
+			for seed := uint64(1); seed <= 20; seed++ {
+				// An odd count splits the orders into unequal halves
+				req := testRequest(randomOrders(rand.New(rand.NewPCG(seed, 0)), 13)...)
+				tt.modify(req)
+				if err := validateRequest(req); err != nil {
+					t.Fatalf("seed %d: validateRequest: %v", seed, err)
+				}
+				internal := toInternalUnits(req)
+				exact, err := newOptimizer(internal, time.Time{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				want := exact.score(exact.FindOptimal())
+
+				mim := baseOptimizer(internal)
+				bestMask := mim.FindMeetInMiddle()
+				if mim.timedOut {
+					t.Fatalf("seed %d: meet-in-the-middle gave up", seed)
+				}
+				if !mim.fits(bestMask) || !mim.isValidSubset(bestMask) || !mim.complete(bestMask) {
+					t.Fatalf("seed %d: meet-in-the-middle picked invalid load %b", seed, bestMask)
+				}
+				if got := mim.score(bestMask); got != want {
+					t.Errorf("seed %d: meet-in-the-middle scored %d, exact %d", seed, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSolveMeetInMiddle(t *testing.T) {
+	req := testRequest(randomOrders(rand.New(rand.NewPCG(7, 0)), 12)...)
+	want := mustSolve(t, req)
+
+	req.Solver = solverMeetInMiddle
+	got := mustSolve(t, req)
+	if !got.Optimal {
+		t.Error("meet-in-the-middle answer not marked optimal")
+	}
+	if got.TotalPayoutCents != want.TotalPayoutCents {
+		t.Errorf("total_payout_cents = %d, exact solver %d", got.TotalPayoutCents, want.TotalPayoutCents)
+	}
+
+	req.Objective = objectiveMaximinMargin
+	if _, err := solve(context.Background(), req, 0); !errors.Is(err, errSolverRefused) {
+		t.Errorf("maximin_margin objective: err = %v, want errSolverRefused", err)
+	}
+}
+
+func TestMeetInMiddleSubsetLimit(t *testing.T) {
+	savedExact, savedSubsets := cfg.ExactMaxOrders, cfg.MaxSubsets
+	defer func() { cfg.ExactMaxOrders, cfg.MaxSubsets = savedExact, savedSubsets }()
+	// 12 orders need two 2^6 half tables, 128 subsets in all
+	cfg.ExactMaxOrders, cfg.MaxSubsets = 4, 100
+
+	req := testRequest(randomOrders(rand.New(rand.NewPCG(7, 0)), 12)...)
+	if err := validateRequest(req); err != nil {
+		t.Fatal(err)
+	}
+	opt := baseOptimizer(toInternalUnits(req))
+	if opt.FindMeetInMiddle(); !opt.timedOut {
+		t.Error("halves over MAX_SUBSETS: meet-in-the-middle didn't give up")
+	}
+	if got := solverPath(12, 0); got != estimatePathGreedy {
+		t.Errorf("path = %s, want %s", got, estimatePathGreedy)
+	}
+	req.Solver = solverMeetInMiddle
+	if _, err := solve(context.Background(), req, 0); !errors.Is(err, errSolverRefused) {
+		t.Errorf("forced meet_in_middle: err = %v, want errSolverRefused", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// nonceStore remembers recently seen X-Nonce values so captured requests
+// can't be replayed. Entries expire after the window. Once maxSize
+// unexpired nonces are held, new ones are refused rather than forgetting
+// one still in its window, which would let its request be replayed.
+type nonceStore struct {
+	mu      sync.Mutex
+	seen    map[string]time.Time // nonce -> expiration
+	order   []string             // insertion order, which is also expiry order
+	window  time.Duration
+	maxSize int
+}
+
+// defaultNonceMaxEntries is NONCE_MAX_ENTRIES when unset or invalid
+const defaultNonceMaxEntries = 100000
+
+// Global nonce store for the solve endpoints
+var globalNonces = newNonceStore(cfg.NonceWindow, cfg.NonceMaxEntries)
+
+func newNonceStore(window time.Duration, maxSize int) *nonceStore {
+	return &nonceStore{
+		seen:    make(map[string]time.Time),
+		window:  window,
+		maxSize: maxSize,
+	}
+}
+
+// Errors from nonceStore.record
+var (
+	errNonceReplayed  = errors.New("X-Nonce has already been used")
+	errNonceStoreFull = errors.New("too many recent nonces to remember another, retry shortly")
+)
+
+// record stores nonce. It returns errNonceReplayed if nonce was already
+// seen within the window, or errNonceStoreFull with the time until the
+// oldest nonce expires when the store has no room.
+func (s *nonceStore) record(nonce string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := now()
+	for len(s.order) > 0 && t.After(s.seen[s.order[0]]) {
+		delete(s.seen, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	if _, ok := s.seen[nonce]; ok {
+		return 0, errNonceReplayed
+	}
+	if len(s.order) >= s.maxSize {
+		return s.seen[s.order[0]].Sub(t), errNonceStoreFull
+	}
+	s.seen[nonce] = t.Add(s.window)
+	s.order = append(s.order, nonce)
+	return 0, nil
+}
+
+// rejectReplays refuses a request whose X-Nonce was already used within
+// NONCE_WINDOW. Requests without the header are let through, so clients
+// opt in by sending one unique nonce per request.
+func rejectReplays(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nonce := r.Header.Get("X-Nonce")
+		if nonce == "" {
+			next(w, r)
+			return
+		}
+		if !validRequestID(nonce) {
+			writeError(w, r, http.StatusBadRequest, errCodeInvalidHeader, "X-Nonce must be printable ASCII, up to 128 characters")
+			return
+		}
+		switch wait, err := globalNonces.record(nonce); {
+		case errors.Is(err, errNonceReplayed):
+			writeError(w, r, http.StatusConflict, errCodeReplay, err.Error())
+			return
+		case err != nil:
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, r, http.StatusServiceUnavailable, errCodeOverloaded, err.Error())
+			return
+		}
+		next(w, r)
+	}
+}
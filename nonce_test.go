@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNonceStore(t *testing.T) {
+	saved := now
+	defer func() { now = saved }()
+	start := time.Now()
+	now = func() time.Time { return start }
+
+	s := newNonceStore(time.Minute, 2)
+	for _, nonce := range []string{"a", "b"} {
+		if _, err := s.record(nonce); err != nil {
+			t.Fatalf("record %s: %v", nonce, err)
+		}
+	}
+	if _, err := s.record("a"); !errors.Is(err, errNonceReplayed) {
+		t.Errorf("replayed a: err %v, want %v", err, errNonceReplayed)
+	}
+	// Full of unexpired nonces: refuse c rather than forget a
+	now = func() time.Time { return start.Add(20 * time.Second) }
+	wait, err := s.record("c")
+	if !errors.Is(err, errNonceStoreFull) || wait != 40*time.Second {
+		t.Errorf("c on a full store: wait %v, err %v; want 40s, %v", wait, err, errNonceStoreFull)
+	}
+	if _, err := s.record("a"); !errors.Is(err, errNonceReplayed) {
+		t.Errorf("a replayed after a full store: err %v, want %v", err, errNonceReplayed)
+	}
+	// Once a and b expire there is room again, and a may be reused
+	now = func() time.Time { return start.Add(time.Minute + time.Second) }
+	for _, nonce := range []string{"c", "a"} {
+		if _, err := s.record(nonce); err != nil {
+			t.Errorf("record %s after expiry: %v", nonce, err)
+		}
+	}
+}
+
+func TestRejectReplaysFull(t *testing.T) {
+	saved := globalNonces
+	defer func() { globalNonces = saved }()
+	globalNonces = newNonceStore(time.Minute, 1)
+
+	handler := rejectReplays(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	send := func(nonce string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/optimize", nil)
+		r.Header.Set("X-Nonce", nonce)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+	tests := []struct {
+		name     string
+		nonce    string
+		wantCode int
+	}{
+		{"new", "n1", http.StatusOK},
+		{"replayed", "n1", http.StatusConflict},
+		{"store full", "n2", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(tt.nonce)
+			if w.Code != tt.wantCode {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.wantCode, w.Body)
+			}
+			if tt.wantCode == http.StatusServiceUnavailable && w.Header().Get("Retry-After") == "" {
+				t.Error("no Retry-After")
+			}
+		})
+	}
+}
+
+func TestNonceMaxEntriesConfig(t *testing.T) {
+	t.Setenv("NONCE_MAX_ENTRIES", "0")
+	if got := loadConfig().NonceMaxEntries; got != defaultNonceMaxEntries {
+		t.Errorf("NONCE_MAX_ENTRIES=0 gives %d, want %d", got, defaultNonceMaxEntries)
+	}
+}
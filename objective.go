@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+)
+
+// Objectives a request can choose with the objective field. The default
+// maximizes the load's total score.
+const (
+	objectiveMaximinMargin     = "maximin_margin"
+	objectiveTargetUtilization = "target_utilization"
+)
+
+// findMaximin picks the valid load whose worst order margin, payout_cents
+// less cost_cents, is highest. Adding an order can only lower the worst
+// margin, so many loads share the best one; among them the highest score
+// wins, then the lowest mask. The empty load counts with a margin of 0, so
+// it wins over loads that lose money on an order. Like FindOptimal, it
+// sets timedOut and returns 0 when the deadline passes, and callers must
+// check timedOut before using the answer.
+func (o *Optimizer) findMaximin() int {
+	bestMask := 0
+	bestMargin := int64(math.MinInt64)
+	bestScore := int64(0)
+	for mask := 0; mask < o.maxMask; mask++ {
+		if o.expired(mask) {
+			return 0
+		}
+		if !o.valid[mask] || !o.complete(mask) {
+			continue
+		}
+		margin := int64(0)
+		if mask != 0 {
+			margin = o.minMargin(mask)
+		}
+		if score := o.dpScore(mask); margin > bestMargin || margin == bestMargin && score > bestScore {
+			bestMask, bestMargin, bestScore = mask, margin, score
+		}
+	}
+	return bestMask
+}
+
+// findTargetUtilization picks the valid load whose weight is closest to
+// targetWeight, over or under; among equally close loads the highest score
+// wins, then the lowest mask. The empty load is a candidate too, so it wins
+// when every load overshoots the target by more than the target itself.
+// Timeouts are reported through timedOut, as in findMaximin.
+func (o *Optimizer) findTargetUtilization() int {
+	bestMask := 0
+	bestDistance := Quantity(-1)
+	bestScore := int64(0)
+	for mask := 0; mask < o.maxMask; mask++ {
+		if o.expired(mask) {
+			return 0
+		}
+		if !o.valid[mask] || !o.complete(mask) {
+			continue
+		}
+		distance := o.weight[mask] - o.targetWeight
+		if distance < 0 {
+			distance = -distance
+		}
+		score := o.dpScore(mask)
+		if bestDistance < 0 || distance < bestDistance || distance == bestDistance && score > bestScore {
+			bestMask, bestDistance, bestScore = mask, distance, score
+		}
+	}
+	return bestMask
+}
+
+// minMargin returns the lowest payout_cents - cost_cents among the orders
+// in a non-empty mask
+func (o *Optimizer) minMargin(mask int) int64 {
+	margin := int64(math.MaxInt64)
+	for m := uint(mask); m != 0; m &= m - 1 {
+		order := o.orders[bits.TrailingZeros(m)]
+		margin = min(margin, order.PayoutCents-order.CostCents)
+	}
+	return margin
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMaximinPrefersEmptyToLosses(t *testing.T) {
+	a := testOrder("a", 1000, 1000, 100)
+	a.CostCents = 1500
+	b := testOrder("b", 500, 1000, 100)
+	b.CostCents = 800
+	req := testRequest(a, b)
+	req.Objective = objectiveMaximinMargin
+	if resp := mustSolve(t, req); len(resp.SelectedOrderIDs) != 0 {
+		t.Errorf("selected %v, want the empty load over money-losing ones", resp.SelectedOrderIDs)
+	}
+
+	// A break-even order still beats the empty load on its total
+	b.CostCents = 500
+	req = testRequest(a, b)
+	req.Objective = objectiveMaximinMargin
+	if resp := mustSolve(t, req); len(resp.SelectedOrderIDs) != 1 || resp.SelectedOrderIDs[0] != "b" {
+		t.Errorf("selected %v, want [b]", resp.SelectedOrderIDs)
+	}
+}
+
+func TestTargetUtilizationEmptyLoad(t *testing.T) {
+	// A 10% target is 4400 lbs; a 9000 lb order is 4600 off, further than
+	// the empty load's 4400
+	req := testRequest(testOrder("a", 1000, 9000, 100))
+	req.Objective = objectiveTargetUtilization
+	req.TargetWeightPercent = 10
+	if resp := mustSolve(t, req); len(resp.SelectedOrderIDs) != 0 {
+		t.Errorf("selected %v, want the empty load", resp.SelectedOrderIDs)
+	}
+
+	req = testRequest(testOrder("a", 1000, 8000, 100))
+	req.Objective = objectiveTargetUtilization
+	req.TargetWeightPercent = 10
+	if resp := mustSolve(t, req); len(resp.SelectedOrderIDs) != 1 {
+		t.Errorf("selected %v, want [a], 3600 lbs off", resp.SelectedOrderIDs)
+	}
+}
+
+func TestObjectiveDeadline(t *testing.T) {
+	for _, objective := range []string{objectiveMaximinMargin, objectiveTargetUtilization} {
+		t.Run(objective, func(t *testing.T) {
+			req := testRequest(testOrder("a", 1000, 1000, 100), testOrder("b", 500, 1000, 100))
+			req.Objective = objective
+			if objective == objectiveTargetUtilization {
+				req.TargetWeightPercent = 50
+			}
+			if err := validateRequest(req); err != nil {
+				t.Fatal(err)
+			}
+			opt, err := newOptimizer(toInternalUnits(req), time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			opt.deadline = time.Now().Add(-time.Second)
+			if mask := opt.FindOptimal(); mask != 0 || !opt.timedOut {
+				t.Errorf("past the deadline: mask %b, timedOut %t; want 0 and true", mask, opt.timedOut)
+			}
+
+			// A forced exact solve reports the timeout rather than an
+			// empty plan
+			req.Solver = solverExact
+			if _, err := solve(context.Background(), req, time.Nanosecond); !errors.Is(err, errSolverRefused) {
+				t.Errorf("forced exact past the deadline: err %v, want %v", err, errSolverRefused)
+			}
+		})
+	}
+}
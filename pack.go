@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+)
+
+// PackRequest asks how few identical trucks can carry every order
+type PackRequest struct {
+	Truck      Truck   `json:"truck"`
+	Orders     []Order `json:"orders"`
+	WeightUnit string  `json:"weight_unit,omitempty"`
+	VolumeUnit string  `json:"volume_unit,omitempty"`
+}
+
+// PackResponse lists one load per truck used. Orders that can't go on the
+// truck even alone are left out and listed separately.
+type PackResponse struct {
+	TrucksNeeded       int                 `json:"trucks_needed"`
+	Loads              []*OptimizeResponse `json:"loads"`
+	UnpackableOrderIDs []string            `json:"unpackable_order_ids"`
+}
+
+// packFirstFitDecreasing assigns orders to trucks largest first, putting each
+// on the first truck where it still fits and is compatible, and opening a new
+// truck when none is. It returns one mask per truck, plus the orders that
+// don't fit an empty truck. The truck count is a heuristic, not a proven
+// minimum.
+func (o *Optimizer) packFirstFitDecreasing() (loads []int, unpackable int) {
+	order := make([]int, o.n)
+	for i := range order {
+		order[i] = i
+	}
+	// Largest share of the scarcer capacity first
+	sort.SliceStable(order, func(a, b int) bool {
+		return o.size(1<<order[a]) > o.size(1<<order[b])
+	})
+
+	for _, i := range order {
+		placed := false
+		for b, load := range loads {
+			if next := load | 1<<i; o.fits(next) && o.isValidSubset(next) {
+				loads[b] = next
+				placed = true
+				break
+			}
+		}
+		if placed {
+			continue
+		}
+		if alone := 1 << i; o.fits(alone) && o.isValidSubset(alone) {
+			loads = append(loads, alone)
+		} else {
+			unpackable |= alone
+		}
+	}
+	return loads, unpackable
+}
+
+// size is the share of the scarcer capacity a mask uses. An ignored
+// dimension takes no share.
+func (o *Optimizer) size(mask int) float64 {
+	_, weight, volume := o.maskTotals(mask)
+	var weightShare, volumeShare float64
+	if !o.ignoreWeight {
+		weightShare = capacityShare(weight, o.truck.freeWeightLbs())
+	}
+	if !o.ignoreVolume {
+		volumeShare = capacityShare(volume, o.truck.freeVolumeCuft())
+	}
+	return max(weightShare, volumeShare)
+}
+
+func packHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req PackRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	optReq := &OptimizeRequest{Truck: req.Truck, Orders: req.Orders, WeightUnit: req.WeightUnit, VolumeUnit: req.VolumeUnit}
+	if err := validateRequest(optReq); err != nil {
+		writeError(w, r, http.StatusUnprocessableEntity, errCodeValidation, err.Error())
+		return
+	}
+
+	internal := toInternalUnits(optReq)
+	opt := baseOptimizer(internal)
+	masks, unpackable := opt.packFirstFitDecreasing()
+
+	resp := PackResponse{
+		TrucksNeeded:       len(masks),
+		Loads:              make([]*OptimizeResponse, 0, len(masks)),
+		UnpackableOrderIDs: []string{},
+	}
+	for _, mask := range masks {
+		load := opt.BuildResponse(mask)
+		finishResponse(load, optReq)
+		resp.Loads = append(resp.Loads, load)
+	}
+	for i, o := range req.Orders {
+		if unpackable&(1<<i) != 0 {
+			resp.UnpackableOrderIDs = append(resp.UnpackableOrderIDs, o.ID)
+		}
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,81 @@
+package main
+
+import "sort"
+
+// maxParetoPoints bounds the frontier returned, keeping the highest payouts
+const maxParetoPoints = 50
+
+// ParetoPoint is one load on the payout/capacity frontier
+type ParetoPoint struct {
+	SelectedOrderIDs         []string `json:"selected_order_ids" xml:"selected_order_ids>id"`
+	TotalPayoutCents         int64    `json:"total_payout_cents" xml:"total_payout_cents"`
+	UtilizationWeightPercent float64  `json:"utilization_weight_percent" xml:"utilization_weight_percent"`
+	UtilizationVolumePercent float64  `json:"utilization_volume_percent" xml:"utilization_volume_percent"`
+}
+
+// paretoFrontier returns the valid loads no other load beats on payout
+// without also using more weight or volume: every extra dollar on the
+// frontier costs capacity. Points are ordered by payout, highest first, and
+// capped at maxParetoPoints. IDs and utilization come from req, the
+// request in the client's units, which shares the optimizer's order indices.
+func (o *Optimizer) paretoFrontier(req *OptimizeRequest) []ParetoPoint {
+	var masks []int
+	for mask := 0; mask < o.maxMask; mask++ {
+		if o.valid[mask] && o.complete(mask) {
+			masks = append(masks, mask)
+		}
+	}
+	// Co-load bonuses are real payout, so they count here too
+	payout := func(mask int) int64 { return o.payout[mask] + o.coLoadBonus(mask) }
+	sort.Slice(masks, func(a, b int) bool {
+		ma, mb := masks[a], masks[b]
+		if payout(ma) != payout(mb) {
+			return payout(ma) > payout(mb)
+		}
+		if o.weight[ma] != o.weight[mb] {
+			return o.weight[ma] < o.weight[mb]
+		}
+		return o.volume[ma] < o.volume[mb]
+	})
+
+	// With payout descending, a load is dominated exactly when an earlier
+	// frontier load uses no more weight and no more volume. An ignored
+	// dimension doesn't count as a cost.
+	var frontier []int
+	for _, mask := range masks {
+		dominated := false
+		for _, f := range frontier {
+			if (o.ignoreWeight || o.weight[f] <= o.weight[mask]) && (o.ignoreVolume || o.volume[f] <= o.volume[mask]) {
+				dominated = true
+				break
+			}
+		}
+		if dominated {
+			continue
+		}
+		frontier = append(frontier, mask)
+		if len(frontier) == maxParetoPoints {
+			break
+		}
+	}
+
+	points := make([]ParetoPoint, 0, len(frontier))
+	for _, mask := range frontier {
+		p := ParetoPoint{SelectedOrderIDs: []string{}, TotalPayoutCents: o.coLoadBonus(mask)}
+		var weight, volume Quantity
+		for i, order := range req.Orders {
+			if mask&(1<<i) != 0 {
+				p.SelectedOrderIDs = append(p.SelectedOrderIDs, order.ID)
+				p.TotalPayoutCents += order.PayoutCents
+				weight = addSaturating(weight, order.WeightLbs)
+				volume = addSaturating(volume, order.VolumeCuft)
+			}
+		}
+		_, weightPct := capacityUsage(weight, req.Truck.freeWeightLbs(), req.IgnoreWeight)
+		_, volumePct := capacityUsage(volume, req.Truck.freeVolumeCuft(), req.IgnoreVolume)
+		p.UtilizationWeightPercent = roundPercent(weightPct)
+		p.UtilizationVolumePercent = roundPercent(volumePct)
+		points = append(points, p)
+	}
+	return points
+}
@@ -0,0 +1,594 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Hand-written protobuf (proto3) encoding for the messages in
+// proto/optimizer.proto. It reads into and writes from the same request and
+// response structs as JSON and XML, including the Quantity fields and their
+// *_hundredths twins, so there is no second set of generated types to copy
+// to and from. google.golang.org/protobuf is in go.mod only because the OTLP
+// trace exporter needs it; nothing here uses it. A field added to the schema
+// must be added here by hand.
+
+const contentTypeProtobuf = "application/x-protobuf"
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("protobuf: truncated message")
+
+// protoEncoder appends fields in wire format. Zero values are omitted, as in proto3.
+type protoEncoder struct {
+	buf []byte
+}
+
+func (e *protoEncoder) tag(field, wireType int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wireType))
+}
+
+func (e *protoEncoder) int64(field int, v int64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+func (e *protoEncoder) bool(field int, v bool) {
+	if !v {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = append(e.buf, 1)
+}
+
+func (e *protoEncoder) double(field int, v float64) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+func (e *protoEncoder) string(field int, v string) {
+	if v == "" {
+		return
+	}
+	e.bytes(field, []byte(v))
+}
+
+func (e *protoEncoder) repeatedString(field int, vs []string) {
+	// Repeated strings keep empty elements, unlike singular fields
+	for _, v := range vs {
+		e.bytes(field, []byte(v))
+	}
+}
+
+// packedInts writes a repeated integer field in proto3's default packed form
+func (e *protoEncoder) packedInts(field int, vs []int) {
+	if len(vs) == 0 {
+		return
+	}
+	var packed []byte
+	for _, v := range vs {
+		packed = binary.AppendUvarint(packed, uint64(v))
+	}
+	e.bytes(field, packed)
+}
+
+func (e *protoEncoder) bytes(field int, v []byte) {
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+// marshalProto encodes the response as an OptimizeResponse message
+func (r *OptimizeResponse) marshalProto() []byte {
+	var e protoEncoder
+	e.string(1, r.TruckID)
+	e.repeatedString(2, r.SelectedOrderIDs)
+	e.repeatedString(3, r.InfeasibleOrderIDs)
+	e.repeatedString(4, r.Origins)
+	e.int64(5, r.TotalPayoutCents)
+	e.int64(6, r.TotalWeightLbs.round())
+	e.int64(7, r.TotalVolumeCuft.round())
+	e.int64(8, r.RemainingWeightLbs.round())
+	e.int64(9, r.RemainingVolumeCuft.round())
+	e.double(10, r.UtilizationWeightPercent)
+	e.double(11, r.UtilizationVolumePercent)
+	e.bool(12, r.Optimal)
+	e.string(13, r.WeightUnit)
+	e.string(14, r.VolumeUnit)
+	if r.OptimalityBoundPercent != nil {
+		e.double(15, *r.OptimalityBoundPercent)
+	}
+	e.repeatedString(16, r.Destinations)
+	if r.Diagnostics != nil {
+		e.bytes(17, r.Diagnostics.marshalProto())
+	}
+	e.string(18, r.BindingConstraint)
+	e.repeatedString(19, r.Warnings)
+	e.string(20, r.PricingSnapshotID)
+	e.int64(21, r.OverageLbs.round())
+	e.int64(22, r.OveragePenaltyCents)
+	e.packedInts(23, r.SelectedOrderIndices)
+	e.int64(24, r.AvgPayoutPerOrderCents)
+	for i := range r.ParetoFrontier {
+		e.bytes(25, r.ParetoFrontier[i].marshalProto())
+	}
+	for i := range r.LineItems {
+		e.bytes(26, r.LineItems[i].marshalProto())
+	}
+	if r.EstimatedCO2Grams != nil {
+		e.int64(27, *r.EstimatedCO2Grams)
+	}
+	e.string(28, r.SolutionHash)
+	e.int64(29, r.CoLoadBonusCents)
+	e.repeatedString(30, r.StableOrderIDs)
+	e.int64(31, r.FixedCostCents)
+	e.bool(32, r.NotWorthDispatching)
+	if r.RejectedOrders != nil {
+		e.bytes(33, r.RejectedOrders.marshalProto())
+	}
+	if r.MinMarginCents != nil {
+		// Optional field: written even when 0, so presence survives
+		e.tag(34, wireVarint)
+		e.buf = binary.AppendUvarint(e.buf, uint64(*r.MinMarginCents))
+	}
+	e.bool(35, r.UsedBaseline)
+	e.bool(36, r.BelowMinPayout)
+	if r.Trace != nil {
+		e.bytes(37, r.Trace.marshalProto())
+	}
+	if r.MarginOverRunnerUpCents != nil {
+		e.int64(38, *r.MarginOverRunnerUpCents)
+	}
+	for i := range r.AxleLoads {
+		e.bytes(39, r.AxleLoads[i].marshalProto())
+	}
+	e.string(40, r.Currency)
+	if r.BestAddition != nil {
+		e.bytes(41, r.BestAddition.marshalProto())
+	}
+	// The exact hundredths behind fields 6 to 9 and 21, which are each
+	// rounded on their own
+	e.int64(42, int64(r.TotalWeightLbs))
+	e.int64(43, int64(r.TotalVolumeCuft))
+	e.int64(44, int64(r.RemainingWeightLbs))
+	e.int64(45, int64(r.RemainingVolumeCuft))
+	e.int64(46, int64(r.OverageLbs))
+	return e.buf
+}
+
+func (d *Diagnostics) marshalProto() []byte {
+	var e protoEncoder
+	e.int64(1, d.SubsetsTotal)
+	e.int64(2, d.SubsetsVisited)
+	e.double(3, d.SubsetsSkippedPercent)
+	if d.SolveDeadlineRemainingMs != nil {
+		// Optional field: written even when 0, so presence survives
+		e.tag(4, wireVarint)
+		e.buf = binary.AppendUvarint(e.buf, uint64(*d.SolveDeadlineRemainingMs))
+	}
+	return e.buf
+}
+
+func (p *ParetoPoint) marshalProto() []byte {
+	var e protoEncoder
+	e.repeatedString(1, p.SelectedOrderIDs)
+	e.int64(2, p.TotalPayoutCents)
+	e.double(3, p.UtilizationWeightPercent)
+	e.double(4, p.UtilizationVolumePercent)
+	return e.buf
+}
+
+func (l *LineItem) marshalProto() []byte {
+	var e protoEncoder
+	e.string(1, l.OrderID)
+	e.int64(2, l.PayoutCents)
+	e.int64(3, l.WeightLbs.round())
+	e.int64(4, l.VolumeCuft.round())
+	e.double(5, l.PayoutPercent)
+	e.double(6, l.WeightPercent)
+	e.double(7, l.VolumePercent)
+	return e.buf
+}
+
+func (a *BestAddition) marshalProto() []byte {
+	var e protoEncoder
+	e.string(1, a.OrderID)
+	e.int64(2, a.PayoutCents)
+	e.bool(3, a.Fits)
+	e.int64(4, a.MakeRoomWeightLbs.round())
+	e.int64(5, a.MakeRoomVolumeCuft.round())
+	e.repeatedString(6, a.OrderIDs)
+	axles := make([]int, len(a.MakeRoomAxleLbs))
+	for i, q := range a.MakeRoomAxleLbs {
+		axles[i] = int(q.round())
+	}
+	e.packedInts(7, axles)
+	return e.buf
+}
+
+func (a *AxleLoad) marshalProto() []byte {
+	var e protoEncoder
+	e.int64(1, int64(a.Axle))
+	e.int64(2, a.WeightLbs.round())
+	e.int64(3, a.CapacityLbs.round())
+	e.double(4, a.UtilizationPercent)
+	return e.buf
+}
+
+func (r *RejectedOrders) marshalProto() []byte {
+	var e protoEncoder
+	e.repeatedString(1, r.OverWeight)
+	e.repeatedString(2, r.OverVolume)
+	e.repeatedString(3, r.HazmatConflict)
+	e.repeatedString(4, r.RouteMismatch)
+	e.repeatedString(5, r.RuleConflict)
+	e.repeatedString(6, r.NotOptimal)
+	return e.buf
+}
+
+func (t *SolveTrace) marshalProto() []byte {
+	var e protoEncoder
+	e.int64(1, t.SubsetsValid)
+	e.int64(2, t.PrunedCapacity)
+	e.int64(3, t.PrunedConstraint)
+	e.int64(4, t.PrunedUnvisited)
+	for i := range t.Pruned {
+		var pe protoEncoder
+		pe.repeatedString(1, t.Pruned[i].OrderIDs)
+		pe.string(2, t.Pruned[i].Reason)
+		e.bytes(5, pe.buf)
+	}
+	e.bool(6, t.PrunedTruncated)
+	e.bytes(7, t.Best.marshalProto())
+	if t.RunnerUp != nil {
+		e.bytes(8, t.RunnerUp.marshalProto())
+	}
+	return e.buf
+}
+
+func (p *TracePlan) marshalProto() []byte {
+	var e protoEncoder
+	e.int64(1, int64(p.Mask))
+	e.repeatedString(2, p.OrderIDs)
+	e.int64(3, p.ScoreCents)
+	return e.buf
+}
+
+// protoField is one decoded field; varint and fixed values land in num64,
+// length-delimited ones in data
+type protoField struct {
+	num      int
+	wireType int
+	num64    uint64
+	data     []byte
+}
+
+// ints decodes a repeated integer field, which arrives either packed, the
+// proto3 default, or as one varint per element
+func (f protoField) ints() ([]int64, error) {
+	if f.wireType != wireBytes {
+		return []int64{int64(f.num64)}, nil
+	}
+	var vs []int64
+	for b := f.data; len(b) > 0; {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errProtoTruncated
+		}
+		vs = append(vs, int64(v))
+		b = b[n:]
+	}
+	return vs, nil
+}
+
+// decodeQuantity stores a whole-unit weight or volume in q. Values too
+// large to hold in hundredths are rejected rather than left to wrap past
+// validation.
+func (f protoField) decodeQuantity(q *Quantity) (err error) {
+	*q, err = protoQuantity(f.num, int64(f.num64))
+	return err
+}
+
+// protoQuantity converts v, a whole-unit value of field, to hundredths
+func protoQuantity(field int, v int64) (Quantity, error) {
+	if v > math.MaxInt64/quantityScale || v < math.MinInt64/quantityScale {
+		return 0, fmt.Errorf("field %d: %d is out of range", field, v)
+	}
+	return wholeQuantity(v), nil
+}
+
+// setHundredths replaces q with a *_hundredths field's value when one was
+// sent. Proto3 can't tell an explicit zero from unset, so a zero leaves the
+// whole-unit value.
+func setHundredths(q *Quantity, hundredths Quantity) {
+	if hundredths != 0 {
+		*q = hundredths
+	}
+}
+
+// walkProto calls fn for each field in a message. Unknown fields are passed
+// through too and callers simply ignore them, per protobuf semantics.
+func walkProto(b []byte, fn func(f protoField) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		b = b[n:]
+		f := protoField{num: int(key >> 3), wireType: int(key & 7)}
+
+		switch f.wireType {
+		case wireVarint:
+			f.num64, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errProtoTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errProtoTruncated
+			}
+			f.num64 = binary.LittleEndian.Uint64(b)
+			b = b[8:]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errProtoTruncated
+			}
+			f.num64 = uint64(binary.LittleEndian.Uint32(b))
+			b = b[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || size > uint64(len(b)-n) {
+				return errProtoTruncated
+			}
+			f.data = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			return errors.New("protobuf: unsupported wire type")
+		}
+
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalProto decodes an OptimizeRequest message
+func (req *OptimizeRequest) unmarshalProto(b []byte) error {
+	return walkProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			return req.Truck.unmarshalProto(f.data)
+		case 2:
+			var o Order
+			if err := o.unmarshalProto(f.data); err != nil {
+				return err
+			}
+			req.Orders = append(req.Orders, o)
+		case 3:
+			req.WeightUnit = string(f.data)
+		case 4:
+			req.VolumeUnit = string(f.data)
+		case 5:
+			req.AllowMultiOrigin = f.num64 != 0
+		case 6:
+			req.MaxOrigins = int(int64(f.num64))
+		case 7:
+			var group []string
+			err := walkProto(f.data, func(g protoField) error {
+				if g.num == 1 {
+					group = append(group, string(g.data))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			req.OrderGroups = append(req.OrderGroups, group)
+		case 8:
+			req.AllowMultiDestination = f.num64 != 0
+		case 9:
+			req.MaxDestinations = int(int64(f.num64))
+		case 10:
+			req.PreferredOrderIDs = append(req.PreferredOrderIDs, string(f.data))
+		case 11:
+			req.PreferenceBonusCents = int64(f.num64)
+		case 12:
+			req.PinnedOrderIDs = append(req.PinnedOrderIDs, string(f.data))
+		case 13:
+			req.GreedySort = string(f.data)
+		case 14:
+			req.PricingSnapshotID = string(f.data)
+		case 15:
+			req.Solver = string(f.data)
+		case 16:
+			req.IgnoreWeight = f.num64 != 0
+		case 17:
+			req.IgnoreVolume = f.num64 != 0
+		case 18:
+			var pair []string
+			err := walkProto(f.data, func(g protoField) error {
+				if g.num == 1 {
+					pair = append(pair, string(g.data))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			req.IncompatiblePairs = append(req.IncompatiblePairs, pair)
+		case 19:
+			seed := int64(f.num64)
+			req.Seed = &seed
+		case 20:
+			req.MaxOrdersPerDestination = int(int64(f.num64))
+		case 21:
+			var b CoLoadBonus
+			err := walkProto(f.data, func(g protoField) error {
+				switch g.num {
+				case 1:
+					b.OrderA = string(g.data)
+				case 2:
+					b.OrderB = string(g.data)
+				case 3:
+					b.BonusCents = int64(g.num64)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			req.CoLoadBonuses = append(req.CoLoadBonuses, b)
+		case 22:
+			req.Objective = string(f.data)
+		case 23:
+			req.BaselineOrderIDs = append(req.BaselineOrderIDs, string(f.data))
+		case 24:
+			req.MinTotalPayoutCents = int64(f.num64)
+		case 25:
+			req.TieBreakByDelivery = f.num64 != 0
+		case 26:
+			req.TargetWeightPercent = math.Float64frombits(f.num64)
+		case 27:
+			req.MaxCategoryPercent = math.Float64frombits(f.num64)
+		}
+		return nil
+	})
+}
+
+func (t *Truck) unmarshalProto(b []byte) error {
+	// The *_hundredths fields win over whole units whatever order the
+	// fields arrive in, so they are applied after the walk
+	var weight, volume, preloadedWeight, preloadedVolume Quantity
+	var axles []Quantity
+	err := walkProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			t.ID = string(f.data)
+		case 2:
+			return f.decodeQuantity(&t.MaxWeightLbs)
+		case 3:
+			return f.decodeQuantity(&t.MaxVolumeCuft)
+		case 4:
+			t.TransitDays = int64(f.num64)
+		case 5:
+			t.WeightOveragePercent = math.Float64frombits(f.num64)
+		case 6:
+			t.OveragePenaltyCentsPerLb = int64(f.num64)
+		case 7:
+			allowed := f.num64 != 0
+			t.HazmatAllowed = &allowed
+		case 8:
+			t.CO2GramsPerMile = int64(f.num64)
+		case 9:
+			t.FixedCostCents = int64(f.num64)
+		case 10:
+			return f.decodeQuantity(&t.PreloadedWeightLbs)
+		case 11:
+			return f.decodeQuantity(&t.PreloadedVolumeCuft)
+		case 12:
+			capacities, err := f.ints()
+			if err != nil {
+				return err
+			}
+			for _, c := range capacities {
+				q, err := protoQuantity(f.num, c)
+				if err != nil {
+					return err
+				}
+				t.AxleCapacities = append(t.AxleCapacities, q)
+			}
+		case 13:
+			weight = Quantity(int64(f.num64))
+		case 14:
+			volume = Quantity(int64(f.num64))
+		case 15:
+			preloadedWeight = Quantity(int64(f.num64))
+		case 16:
+			preloadedVolume = Quantity(int64(f.num64))
+		case 17:
+			capacities, err := f.ints()
+			if err != nil {
+				return err
+			}
+			for _, c := range capacities {
+				axles = append(axles, Quantity(c))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	setHundredths(&t.MaxWeightLbs, weight)
+	setHundredths(&t.MaxVolumeCuft, volume)
+	setHundredths(&t.PreloadedWeightLbs, preloadedWeight)
+	setHundredths(&t.PreloadedVolumeCuft, preloadedVolume)
+	if len(axles) > 0 {
+		t.AxleCapacities = axles
+	}
+	return nil
+}
+
+func (o *Order) unmarshalProto(b []byte) error {
+	// Applied after the walk, like Truck's
+	var weight, volume Quantity
+	err := walkProto(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			o.ID = string(f.data)
+		case 2:
+			o.PayoutCents = int64(f.num64)
+		case 3:
+			return f.decodeQuantity(&o.WeightLbs)
+		case 4:
+			return f.decodeQuantity(&o.VolumeCuft)
+		case 5:
+			o.Origin = string(f.data)
+		case 6:
+			o.Destination = string(f.data)
+		case 7:
+			o.PickupDate = string(f.data)
+		case 8:
+			o.DeliveryDate = string(f.data)
+		case 9:
+			o.IsHazmat = f.num64 != 0
+		case 10:
+			o.DistanceMiles = int64(f.num64)
+		case 11:
+			o.CostCents = int64(f.num64)
+		case 12:
+			o.Category = string(f.data)
+		case 13:
+			o.AxlePosition = int(int64(f.num64))
+		case 14:
+			o.Currency = string(f.data)
+		case 15:
+			weight = Quantity(int64(f.num64))
+		case 16:
+			volume = Quantity(int64(f.num64))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	setHundredths(&o.WeightLbs, weight)
+	setHundredths(&o.VolumeCuft, volume)
+	return nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalProtoQuantityRange(t *testing.T) {
+	const limit = math.MaxInt64 / quantityScale
+	tests := []struct {
+		name    string
+		truck   func(e *protoEncoder, v int64)
+		order   func(e *protoEncoder, v int64)
+		v       int64
+		wantErr bool
+	}{
+		{"truck weight at the limit", func(e *protoEncoder, v int64) { e.int64(2, v) }, nil, limit, false},
+		{"truck weight over", func(e *protoEncoder, v int64) { e.int64(2, v) }, nil, limit + 1, true},
+		{"truck weight under", func(e *protoEncoder, v int64) { e.int64(2, v) }, nil, -limit - 1, true},
+		{"preload over", func(e *protoEncoder, v int64) { e.int64(10, v) }, nil, limit + 1, true},
+		{"axle capacity over", func(e *protoEncoder, v int64) { e.packedInts(12, []int{1000, int(v)}) }, nil, limit + 1, true},
+		{"order weight at the limit", nil, func(e *protoEncoder, v int64) { e.int64(3, v) }, limit, false},
+		{"order volume over", nil, func(e *protoEncoder, v int64) { e.int64(4, v) }, limit + 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var truck, order, req protoEncoder
+			if tt.truck != nil {
+				tt.truck(&truck, tt.v)
+			}
+			if tt.order != nil {
+				tt.order(&order, tt.v)
+			}
+			req.bytes(1, truck.buf)
+			req.bytes(2, order.buf)
+
+			var got OptimizeRequest
+			err := got.unmarshalProto(req.buf)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "out of range") {
+					t.Errorf("err %v, want out of range", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Only one of the two is set
+			if q := got.Truck.MaxWeightLbs + got.Orders[0].WeightLbs; q != limit*quantityScale {
+				t.Errorf("decoded %d hundredths, want %d", q, limit*quantityScale)
+			}
+		})
+	}
+}
+
+func TestMarshalProtoHundredths(t *testing.T) {
+	// 12.5 and 87.5 lbs round to 13 and 88, one over the 100 lb truck
+	resp := OptimizeResponse{
+		TotalWeightLbs:      1250,
+		RemainingWeightLbs:  8750,
+		TotalVolumeCuft:     wholeQuantity(40),
+		RemainingVolumeCuft: wholeQuantity(60),
+	}
+	got := make(map[int]int64)
+	err := walkProto(resp.marshalProto(), func(f protoField) error {
+		got[f.num] = int64(f.num64)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]int64{
+		6: 13, 8: 88, 7: 40, 9: 60,
+		42: 1250, 44: 8750, 43: 4000, 45: 6000,
+	}
+	for field, v := range want {
+		if got[field] != v {
+			t.Errorf("field %d = %d, want %d", field, got[field], v)
+		}
+	}
+	if got[42]+got[44] != 10000 {
+		t.Errorf("hundredths add up to %d, want the truck's 10000", got[42]+got[44])
+	}
+}
+
+func TestUnmarshalProtoHundredths(t *testing.T) {
+	var truck, order, req protoEncoder
+	truck.int64(13, 1005) // 10.05 lbs
+	truck.int64(2, 99)    // whole units, replaced by field 13
+	truck.packedInts(17, []int{550, 455})
+	order.int64(15, 1250) // 12.5 lbs
+	order.int64(4, 3)     // whole units, no hundredths sent
+	req.bytes(1, truck.buf)
+	req.bytes(2, order.buf)
+
+	var got OptimizeRequest
+	if err := got.unmarshalProto(req.buf); err != nil {
+		t.Fatal(err)
+	}
+	if got.Truck.MaxWeightLbs != 1005 {
+		t.Errorf("max_weight_lbs = %v, want 10.05", got.Truck.MaxWeightLbs)
+	}
+	if len(got.Truck.AxleCapacities) != 2 || got.Truck.AxleCapacities[0] != 550 || got.Truck.AxleCapacities[1] != 455 {
+		t.Errorf("axle_capacities = %v, want [5.5 4.55]", got.Truck.AxleCapacities)
+	}
+	if got.Orders[0].WeightLbs != 1250 || got.Orders[0].VolumeCuft != wholeQuantity(3) {
+		t.Errorf("order weight %v, volume %v, want 12.5 and 3", got.Orders[0].WeightLbs, got.Orders[0].VolumeCuft)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// quantityScale is the fixed-point precision of weights and volumes. Sums
+// stay exact integers, so capacity checks never drift the way float sums do.
+const (
+	quantityScale    = 100
+	quantityDecimals = 2
+)
+
+// Quantity is a weight or volume in hundredths of its unit: 12.5 lbs is
+// 1250. JSON carries it as a plain number with up to two decimals.
+type Quantity int64
+
+// wholeQuantity converts a whole number of units
+func wholeQuantity(n int64) Quantity {
+	return Quantity(n * quantityScale)
+}
+
+// units returns q in whole units and fractions of one, for ratios and display
+func (q Quantity) units() float64 {
+	return float64(q) / quantityScale
+}
+
+// round returns q to the nearest whole unit, halves away from zero, for
+// wire formats that only carry whole units
+func (q Quantity) round() int64 {
+	return int64(math.Round(q.units()))
+}
+
+func (q Quantity) String() string {
+	s := strconv.FormatInt(int64(q/quantityScale), 10)
+	frac := int64(q % quantityScale)
+	if frac == 0 {
+		return s
+	}
+	if q < 0 {
+		frac = -frac
+		if q > -quantityScale {
+			s = "-" + s
+		}
+	}
+	digits := fmt.Sprintf("%0*d", quantityDecimals, frac)
+	return s + "." + strings.TrimRight(digits, "0")
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// MarshalText gives XML responses the same decimal form as JSON
+func (q Quantity) MarshalText() ([]byte, error) {
+	return []byte(q.String()), nil
+}
+
+// UnmarshalJSON parses the decimal text exactly rather than through a
+// float, rejecting more than two decimals instead of rounding them away
+func (q *Quantity) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > quantityDecimals {
+		return fmt.Errorf("%s has more than %d decimal places", s, quantityDecimals)
+	}
+	if strings.ContainsAny(s, "eE") {
+		return fmt.Errorf("%s must be a plain decimal number", s)
+	}
+	for len(frac) < quantityDecimals {
+		frac += "0"
+	}
+	n, err := strconv.ParseInt(whole+frac, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s is not a number in range", s)
+	}
+	*q = Quantity(n)
+	return nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// rateLimiter gives each client a token bucket holding up to burst
+// requests, refilled at perMinute. Buckets that have refilled completely
+// hold nothing worth keeping, so they are dropped once maxSize clients are
+// tracked; if every bucket is in use, an arbitrary one is dropped and that
+// client starts over with a full bucket.
+type rateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	perMinute int // zero disables limiting
+	burst     int
+	maxSize   int
+}
+
+// tokenBucket is one client's remaining requests as of updated
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// rateLimitMaxClients bounds how many clients' buckets are tracked at once
+const rateLimitMaxClients = 100000
+
+// Global rate limiter for the solve endpoints
+var globalRates = newRateLimiter(cfg.RateLimitPerMinute, cfg.RateLimitBurst, rateLimitMaxClients)
+
+func newRateLimiter(perMinute, burst, maxSize int) *rateLimiter {
+	return &rateLimiter{
+		buckets:   make(map[string]*tokenBucket),
+		perMinute: perMinute,
+		burst:     burst,
+		maxSize:   maxSize,
+	}
+}
+
+// take spends one of client's tokens at t and reports whether it had one.
+// It also returns the whole tokens left, how long until the next token
+// arrives when there were none, and when the bucket will be full again.
+func (l *rateLimiter) take(client string, t time.Time) (ok bool, remaining int, wait time.Duration, reset time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	perToken := max(time.Minute/time.Duration(l.perMinute), 1)
+	b, found := l.buckets[client]
+	if !found {
+		if len(l.buckets) >= l.maxSize {
+			l.evict(t, perToken)
+		}
+		b = &tokenBucket{tokens: float64(l.burst), updated: t}
+		l.buckets[client] = b
+	}
+	b.tokens = min(float64(l.burst), b.tokens+float64(t.Sub(b.updated))/float64(perToken))
+	b.updated = t
+
+	if b.tokens >= 1 {
+		b.tokens--
+		ok = true
+	} else {
+		wait = time.Duration((1 - b.tokens) * float64(perToken))
+	}
+	reset = t.Add(time.Duration((float64(l.burst) - b.tokens) * float64(perToken)))
+	return ok, int(b.tokens), wait, reset
+}
+
+// evict drops the buckets that have refilled by t, or an arbitrary one if
+// none has. The caller must hold l.mu.
+func (l *rateLimiter) evict(t time.Time, perToken time.Duration) {
+	for client, b := range l.buckets {
+		if b.tokens+float64(t.Sub(b.updated))/float64(perToken) >= float64(l.burst) {
+			delete(l.buckets, client)
+		}
+	}
+	for client := range l.buckets {
+		if len(l.buckets) < l.maxSize {
+			break
+		}
+		delete(l.buckets, client)
+	}
+}
+
+// maxRateLimitJitter bounds the random delay added to a 429's Retry-After
+const maxRateLimitJitter = time.Second
+
+// rateLimited applies RATE_LIMIT_PER_MINUTE per client IP. Every response
+// reports the client's quota in X-RateLimit-Remaining and, as Unix seconds,
+// when the bucket is full again in X-RateLimit-Reset. An empty bucket gets
+// 429 with a Retry-After of the time until the next token plus up to
+// maxRateLimitJitter, so limited clients don't all retry at once. The
+// connection's address is the client; X-Forwarded-For isn't trusted.
+func rateLimited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if globalRates.perMinute == 0 {
+			next(w, r)
+			return
+		}
+		client, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			client = r.RemoteAddr
+		}
+
+		ok, remaining, wait, reset := globalRates.take(client, now())
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(int64(math.Ceil(float64(reset.UnixNano())/1e9)), 10))
+		if !ok {
+			wait += rand.N(maxRateLimitJitter)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, errCodeRateLimited, "rate limit exceeded, retry after Retry-After seconds")
+			return
+		}
+		next(w, r)
+	}
+}
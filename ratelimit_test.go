@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	// One request a second, in bursts of two
+	l := newRateLimiter(60, 2, 10)
+	t0 := time.Unix(1_700_000_000, 0)
+	steps := []struct {
+		client    string
+		at        time.Duration
+		ok        bool
+		remaining int
+		wait      time.Duration
+		reset     time.Duration
+	}{
+		{"a", 0, true, 1, 0, time.Second},
+		{"a", 0, true, 0, 0, 2 * time.Second},
+		{"a", 0, false, 0, time.Second, 2 * time.Second},
+		{"a", 500 * time.Millisecond, false, 0, 500 * time.Millisecond, 2 * time.Second},
+		{"b", 500 * time.Millisecond, true, 1, 0, 1500 * time.Millisecond},
+		{"a", time.Second, true, 0, 0, 3 * time.Second},
+		{"a", 10 * time.Second, true, 1, 0, 11 * time.Second}, // refills to the burst, no further
+	}
+	for i, s := range steps {
+		ok, remaining, wait, reset := l.take(s.client, t0.Add(s.at))
+		if ok != s.ok || remaining != s.remaining || wait != s.wait || !reset.Equal(t0.Add(s.reset)) {
+			t.Errorf("step %d: take = %t, %d, %s, +%s; want %t, %d, %s, +%s",
+				i, ok, remaining, wait, reset.Sub(t0), s.ok, s.remaining, s.wait, s.reset)
+		}
+	}
+}
+
+func TestRateLimiterEvictsFullBuckets(t *testing.T) {
+	l := newRateLimiter(60, 2, 2)
+	t0 := time.Unix(1_700_000_000, 0)
+	l.take("a", t0)
+	l.take("b", t0.Add(time.Second))
+	l.take("b", t0.Add(time.Second))
+	// By now a has refilled, b hasn't, so a makes room for c
+	l.take("c", t0.Add(2*time.Second))
+	if _, ok := l.buckets["a"]; ok || len(l.buckets) != 2 {
+		t.Errorf("buckets %v, want b and c", l.buckets)
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	saved := globalRates
+	defer func() { globalRates = saved }()
+	globalRates = newRateLimiter(60, 1, 10)
+
+	handler := rateLimited(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+	send := func() *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/optimize", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	w := send()
+	if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != "0" || w.Header().Get("X-RateLimit-Reset") == "" {
+		t.Fatalf("first request: status %d, headers %v", w.Code, w.Header())
+	}
+
+	w = send()
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request: status %d, want 429", w.Code)
+	}
+	// The next token is under a second away, plus under a second of jitter
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry < 1 || retry > 2 {
+		t.Errorf("Retry-After = %q, want 1 or 2", w.Header().Get("Retry-After"))
+	}
+	reset, err := strconv.ParseInt(w.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset < time.Now().Unix() || reset > time.Now().Add(2*time.Second).Unix() {
+		t.Errorf("X-RateLimit-Reset = %q, want within the next second", w.Header().Get("X-RateLimit-Reset"))
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+// RejectedOrders groups the orders left off the load by why, so a
+// dispatcher sees the shape of the problem at a glance. Each order is in
+// exactly one bucket, the first that applies in field order.
+type RejectedOrders struct {
+	// Heavier or bulkier than the truck, or its axle group, has room for,
+	// even alone
+	OverWeight []string `json:"over_weight,omitempty" xml:"over_weight>id,omitempty"`
+	OverVolume []string `json:"over_volume,omitempty" xml:"over_volume>id,omitempty"`
+	// Would mix hazmat with other freight, or needs a certified truck
+	HazmatConflict []string `json:"hazmat_conflict,omitempty" xml:"hazmat_conflict>id,omitempty"`
+	// Would ride with an order incompatible_pairs keeps it apart from, or
+	// put its category over max_category_percent of the load's weight
+	RuleConflict []string `json:"rule_conflict,omitempty" xml:"rule_conflict>id,omitempty"`
+	// Would add more origins or destinations than the load may have, break
+	// max_orders_per_destination, or miss the shared trip's delivery dates
+	RouteMismatch []string `json:"route_mismatch,omitempty" xml:"route_mismatch>id,omitempty"`
+	// Could join the load but other orders paid more for the space, the
+	// truck's or an axle group's
+	NotOptimal []string `json:"not_optimal,omitempty" xml:"not_optimal>id,omitempty"`
+}
+
+// rejectedOrders sorts each order missing from mask into a RejectedOrders
+// bucket, or returns nil when every order was selected. An order is judged
+// together with its order group, since it can only join with it.
+func (o *Optimizer) rejectedOrders(mask int) *RejectedOrders {
+	if mask == 1<<o.n-1 {
+		return nil
+	}
+	rejected := &RejectedOrders{}
+	for i := 0; i < o.n; i++ {
+		bit := 1 << i
+		if mask&bit != 0 {
+			continue
+		}
+		order := o.orders[i]
+		candidate := mask | bit
+		for _, g := range o.groups {
+			if g&bit != 0 {
+				candidate |= g
+			}
+		}
+		switch {
+		case order.WeightLbs > o.weightCap || o.overAxle(i):
+			rejected.OverWeight = append(rejected.OverWeight, order.ID)
+		case order.VolumeCuft > o.volumeCap:
+			rejected.OverVolume = append(rejected.OverVolume, order.ID)
+		case o.hazmatConflict(candidate):
+			rejected.HazmatConflict = append(rejected.HazmatConflict, order.ID)
+		case o.pairConflict(candidate) || o.overweightCategory(candidate) != 0:
+			rejected.RuleConflict = append(rejected.RuleConflict, order.ID)
+		case o.tooManyStops(candidate) || o.routeConflict(candidate):
+			rejected.RouteMismatch = append(rejected.RouteMismatch, order.ID)
+		default:
+			rejected.NotOptimal = append(rejected.NotOptimal, order.ID)
+		}
+	}
+	return rejected
+}
+
+// hazmatConflict reports whether mask mixes hazmat with other freight, or
+// carries hazmat on a truck that isn't certified for it
+func (o *Optimizer) hazmatConflict(mask int) bool {
+	var hasHazmat, hasNonHazmat bool
+	for i := 0; i < o.n; i++ {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		if o.orders[i].IsHazmat {
+			hasHazmat = true
+		} else {
+			hasNonHazmat = true
+		}
+	}
+	return hasHazmat && (hasNonHazmat || !o.truck.allowsHazmat())
+}
+
+// pairConflict reports whether mask holds both orders of an
+// incompatible_pairs entry
+func (o *Optimizer) pairConflict(mask int) bool {
+	if o.conflicts == nil {
+		return false
+	}
+	for i := 0; i < o.n; i++ {
+		if mask&(1<<i) != 0 && mask&o.conflicts[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// routeConflict reports whether mask breaks a rule of isValidSubset other
+// than an axle limit. Called once hazmatConflict, pairConflict and
+// tooManyStops have passed, that leaves max_orders_per_destination and the
+// shared trip's delivery dates. Axle groups are left out since, like the
+// truck's capacity, their room is what the selected orders paid for.
+func (o *Optimizer) routeConflict(mask int) bool {
+	axles := o.axleMasks
+	o.axleMasks = nil
+	defer func() { o.axleMasks = axles }()
+	return !o.isValidSubset(mask)
+}
+
+// tooManyStops reports whether mask has more distinct origins or
+// destinations than a load may have
+func (o *Optimizer) tooManyStops(mask int) bool {
+	var origins, destinations []string
+	for i := 0; i < o.n; i++ {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		if !containsFold(origins, o.orders[i].Origin) {
+			origins = append(origins, o.orders[i].Origin)
+		}
+		if !containsFold(destinations, o.orders[i].Destination) {
+			destinations = append(destinations, o.orders[i].Destination)
+		}
+	}
+	return len(origins) > o.maxOrigins || len(destinations) > o.maxDestinations
+}
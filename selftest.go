@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+)
+
+// selfTestRequest is a small load with a known answer. Taking the biggest
+// order first (a) leaves room only for d; the optimum fills the truck
+// exactly with b and c.
+// The hazmat order pays well alone but can't join any other order. Dates are
+// relative so REJECT_PAST_DATES can't fail it.
+var selfTestRequest = OptimizeRequest{
+	Truck: Truck{ID: "self-test", MaxWeightLbs: wholeQuantity(10000), MaxVolumeCuft: wholeQuantity(1000)},
+	Orders: []Order{
+		{ID: "a", PayoutCents: 6000, WeightLbs: wholeQuantity(6000), VolumeCuft: wholeQuantity(100), Origin: "A", Destination: "B", PickupDate: "today", DeliveryDate: "today+1"},
+		{ID: "b", PayoutCents: 5000, WeightLbs: wholeQuantity(5000), VolumeCuft: wholeQuantity(100), Origin: "A", Destination: "B", PickupDate: "today", DeliveryDate: "today+1"},
+		{ID: "c", PayoutCents: 5000, WeightLbs: wholeQuantity(5000), VolumeCuft: wholeQuantity(100), Origin: "A", Destination: "B", PickupDate: "today", DeliveryDate: "today+1"},
+		{ID: "d", PayoutCents: 1000, WeightLbs: wholeQuantity(500), VolumeCuft: wholeQuantity(100), Origin: "A", Destination: "B", PickupDate: "today", DeliveryDate: "today+1"},
+		{ID: "e", PayoutCents: 3000, WeightLbs: wholeQuantity(100), VolumeCuft: wholeQuantity(10), Origin: "A", Destination: "B", PickupDate: "today", DeliveryDate: "today+1", IsHazmat: true},
+	},
+}
+
+// Expected answer for selfTestRequest
+var (
+	selfTestPayoutCents = int64(10000)
+	selfTestOrderIDs    = []string{"b", "c"}
+)
+
+// runSelfTest exits if checkSolver fails, so a solver regression fails the
+// deploy instead of serving bad plans
+func runSelfTest() {
+	if err := checkSolver(); err != nil {
+		log.Fatalf("self-test: %v", err)
+	}
+	log.Println("self-test passed")
+}
+
+// checkSolver solves selfTestRequest with the exact solver, bypassing the
+// cache, and reports an error if the answer is wrong
+func checkSolver() error {
+	// validateRequest rewrites relative dates in place, so the global's
+	// orders must not be shared
+	req := selfTestRequest
+	req.Orders = slices.Clone(selfTestRequest.Orders)
+	// The self-test checks the exact solver, whatever EXACT_MAX_ORDERS would
+	// pick for five orders
+	req.Solver = solverExact
+	if err := validateRequest(&req); err != nil {
+		return fmt.Errorf("invalid request: %w", err)
+	}
+	resp, err := solve(context.Background(), &req, 0)
+	if err != nil {
+		return fmt.Errorf("solve failed: %w", err)
+	}
+	if resp.TotalPayoutCents != selfTestPayoutCents || !slices.Equal(resp.SelectedOrderIDs, selfTestOrderIDs) {
+		return fmt.Errorf("got payout %d with orders %v, want %d with %v",
+			resp.TotalPayoutCents, resp.SelectedOrderIDs, selfTestPayoutCents, selfTestOrderIDs)
+	}
+	return nil
+}
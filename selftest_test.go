@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDeepHealthCheck(t *testing.T) {
+	savedNow, savedPast := now, cfg.RejectPastDates
+	defer func() { now, cfg.RejectPastDates = savedNow, savedPast }()
+	cfg.RejectPastDates = true
+
+	probe := func() int {
+		rec := httptest.NewRecorder()
+		healthHandler(rec, httptest.NewRequest(http.MethodGet, "/healthz?deep=true", nil))
+		return rec.Code
+	}
+
+	start := time.Now()
+	now = func() time.Time { return start }
+	if code := probe(); code != http.StatusOK {
+		t.Fatalf("first probe: status %d, want %d", code, http.StatusOK)
+	}
+	// A later probe must still see relative dates, not the ones the first
+	// probe resolved
+	now = func() time.Time { return start.AddDate(0, 0, 3) }
+	if code := probe(); code != http.StatusOK {
+		t.Fatalf("probe 3 days later: status %d, want %d", code, http.StatusOK)
+	}
+	if got := selfTestRequest.Orders[0].PickupDate; got != "today" {
+		t.Errorf("selfTestRequest pickup_date = %q after probes, want %q", got, "today")
+	}
+
+	// Concurrent probes must not share the orders (run with -race)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code := probe(); code != http.StatusOK {
+				t.Errorf("concurrent probe: status %d, want %d", code, http.StatusOK)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSelfTestForcesExact(t *testing.T) {
+	// With these lowered, solve would send five orders to greedy, which
+	// takes a and misses the optimum
+	savedExact, savedMim := cfg.ExactMaxOrders, cfg.MimMaxOrders
+	defer func() { cfg.ExactMaxOrders, cfg.MimMaxOrders = savedExact, savedMim }()
+	cfg.ExactMaxOrders, cfg.MimMaxOrders = 2, 2
+	if err := checkSolver(); err != nil {
+		t.Errorf("checkSolver: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import "sort"
+
+// maxTopK bounds how many plans ?stable_top_k may compare
+const maxTopK = 100
+
+// topK returns up to k of the highest-scoring valid loads, best first, scored
+// the way FindOptimal scores them. Ties keep the lower mask first, so the
+// first load is the one FindOptimal picks, and tie_break_by_delivery
+// reorders ties the same way. The empty load is left out unless
+// nothing else is valid.
+func (o *Optimizer) topK(k int) []int {
+	var masks []int
+	for mask := 1; mask < o.maxMask; mask++ {
+		if o.valid[mask] && o.complete(mask) && o.dpScore(mask) > 0 {
+			masks = append(masks, mask)
+		}
+	}
+	if len(masks) == 0 {
+		return []int{0}
+	}
+	sort.SliceStable(masks, func(a, b int) bool {
+		if sa, sb := o.dpScore(masks[a]), o.dpScore(masks[b]); sa != sb {
+			return sa > sb
+		}
+		return o.moreUrgent(masks[a], masks[b])
+	})
+	if len(masks) > k {
+		masks = masks[:k]
+	}
+	return masks
+}
+
+// stableOrderIDs returns the orders selected in every one of the k best
+// loads, in request order: assignments that hold up even if the plan shifts
+// to a near-optimal alternative. IDs come from req, which shares the
+// optimizer's order indices.
+func (o *Optimizer) stableOrderIDs(req *OptimizeRequest, k int) []string {
+	common := o.maxMask - 1
+	for _, mask := range o.topK(k) {
+		common &= mask
+	}
+	ids := []string{}
+	for i, order := range req.Orders {
+		if common&(1<<i) != 0 {
+			ids = append(ids, order.ID)
+		}
+	}
+	return ids
+}
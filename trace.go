@@ -0,0 +1,102 @@
+package main
+
+import "math/bits"
+
+// maxTracePruned caps the pruned subsets listed in a trace; the counts
+// still cover all of them
+const maxTracePruned = 20
+
+// Why a subset was pruned
+const (
+	pruneCapacity   = "capacity"
+	pruneConstraint = "constraint"
+)
+
+// SolveTrace is an audit record of how the exact solver reached its
+// answer, only with ?trace=true
+type SolveTrace struct {
+	// Subsets the DP found valid, of any size
+	SubsetsValid int64 `json:"subsets_valid" xml:"subsets_valid"`
+	// Subsets the DP visited and rejected: over a capacity, or within it
+	// but breaking a compatibility rule
+	PrunedCapacity   int64 `json:"pruned_capacity" xml:"pruned_capacity"`
+	PrunedConstraint int64 `json:"pruned_constraint" xml:"pruned_constraint"`
+	// Supersets of pruned subsets, rejected without being visited
+	PrunedUnvisited int64 `json:"pruned_unvisited" xml:"pruned_unvisited"`
+	// The first pruned subsets in mask order, up to maxTracePruned. Every
+	// unvisited subset contains a pruned one.
+	Pruned          []TracePrune `json:"pruned" xml:"pruned>subset"`
+	PrunedTruncated bool         `json:"pruned_truncated,omitempty" xml:"pruned_truncated,omitempty"`
+	// The load FindOptimal chose and the best one after it. The chosen load
+	// is the solver's; baseline_order_ids or a payout floor may still
+	// replace it in the response.
+	Best     TracePlan  `json:"best" xml:"best"`
+	RunnerUp *TracePlan `json:"runner_up,omitempty" xml:"runner_up,omitempty"`
+}
+
+// TracePrune is one subset the DP rejected, and why
+type TracePrune struct {
+	OrderIDs []string `json:"order_ids" xml:"order_ids>id"`
+	Reason   string   `json:"reason" xml:"reason"`
+}
+
+// TracePlan is one complete load and its solver score in cents
+type TracePlan struct {
+	Mask       int      `json:"mask" xml:"mask"`
+	OrderIDs   []string `json:"order_ids" xml:"order_ids>id"`
+	ScoreCents int64    `json:"score_cents" xml:"score_cents"`
+}
+
+// trace rebuilds the DP's decisions from its tables. A visited subset still
+// holds its weight and volume sums, so an invalid one that fits was rejected
+// by a rule. IDs come from req, which shares the optimizer's order indices.
+func (o *Optimizer) trace(req *OptimizeRequest, best int) *SolveTrace {
+	t := &SolveTrace{Pruned: []TracePrune{}}
+	runnerUp := -1
+	var runnerUpScore int64
+	for mask := 1; mask < o.maxMask; mask++ {
+		if o.valid[mask] {
+			t.SubsetsValid++
+			if mask != best && o.complete(mask) {
+				if score := o.dpScore(mask); runnerUp < 0 || score > runnerUpScore {
+					runnerUp, runnerUpScore = mask, score
+				}
+			}
+			continue
+		}
+		// Mirrors debug.go: the DP reaches a mask only from the valid subset
+		// without its top bit
+		if !o.valid[mask&^(1<<(bits.Len(uint(mask))-1))] {
+			t.PrunedUnvisited++
+			continue
+		}
+		reason := pruneConstraint
+		if o.fits(mask) {
+			t.PrunedConstraint++
+		} else {
+			reason = pruneCapacity
+			t.PrunedCapacity++
+		}
+		if len(t.Pruned) < maxTracePruned {
+			t.Pruned = append(t.Pruned, TracePrune{OrderIDs: maskIDs(req, mask), Reason: reason})
+		} else {
+			t.PrunedTruncated = true
+		}
+	}
+	t.Best = TracePlan{Mask: best, OrderIDs: maskIDs(req, best), ScoreCents: o.dpScore(best)}
+	if runnerUp >= 0 {
+		t.RunnerUp = &TracePlan{Mask: runnerUp, OrderIDs: maskIDs(req, runnerUp), ScoreCents: runnerUpScore}
+	}
+	return t
+}
+
+// maskIDs lists the IDs of the orders in mask, in request order
+func maskIDs(req *OptimizeRequest, mask int) []string {
+	ids := []string{}
+	for i, order := range req.Orders {
+		if mask&(1<<i) != 0 {
+			ids = append(ids, order.ID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestSolveSpans(t *testing.T) {
+	// tracer delegates to the first provider registered, so this one stays
+	// for the rest of the tests
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+
+	// A payout no other test uses, so the cache can't already hold it
+	body, err := json.Marshal(testRequest(testOrder("a", 1000, 1000, 987654)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/optimize", bytes.NewReader(body))
+	r.Header.Set("Content-Type", contentTypeJSON)
+	w := httptest.NewRecorder()
+	optimizeHandler(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	root, ok := spans["solve"]
+	if !ok {
+		t.Fatalf("no solve span among %d", len(spans))
+	}
+	// The cache lookup and the solver run under the handler's span
+	for _, name := range []string{"cache.lookup", "solver"} {
+		s, ok := spans[name]
+		if !ok {
+			t.Errorf("no %s span", name)
+			continue
+		}
+		if s.Parent().SpanID() != root.SpanContext().SpanID() {
+			t.Errorf("%s span's parent is %s, want the solve span %s", name, s.Parent().SpanID(), root.SpanContext().SpanID())
+		}
+	}
+}
+
+func TestSetupTracingDisabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	saved := otel.GetTracerProvider()
+
+	shutdown, err := setupTracing(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if otel.GetTracerProvider() != saved {
+		t.Error("a provider was registered with no OTLP endpoint")
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown: %v", err)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+// Supported measurement units. The solver works in lbs and cuft internally.
+const (
+	unitLbs  = "lbs"
+	unitKg   = "kg"
+	unitCuft = "cuft"
+	unitM3   = "m3"
+)
+
+// Conversion factors as exact integer ratios so sums never drift:
+//
+//	1 lb   = 0.45359237 kg exactly     => lbs  = kg * 100000000 / 45359237
+//	1 cuft = 0.028316846592 m3 exactly => cuft = m3 * 1953125000 / 55306341
+const (
+	lbsPerKgNum  = 100000000
+	lbsPerKgDen  = 45359237
+	cuftPerM3Num = 1953125000
+	cuftPerM3Den = 55306341
+)
+
+// validateUnits rejects unknown unit strings and values that would overflow once converted
+func validateUnits(req *OptimizeRequest) error {
+	switch req.WeightUnit {
+	case "", unitLbs, unitKg:
+	default:
+		return fmt.Errorf("weight_unit must be %q or %q", unitLbs, unitKg)
+	}
+	switch req.VolumeUnit {
+	case "", unitCuft, unitM3:
+	default:
+		return fmt.Errorf("volume_unit must be %q or %q", unitCuft, unitM3)
+	}
+
+	if req.WeightUnit == unitKg {
+		if _, ok := scale(req.Truck.MaxWeightLbs, lbsPerKgNum, lbsPerKgDen, false); !ok {
+			return fmt.Errorf("truck.max_weight_lbs is too large")
+		}
+		if _, ok := scale(req.Truck.PreloadedWeightLbs, lbsPerKgNum, lbsPerKgDen, true); !ok {
+			return fmt.Errorf("truck.preloaded_weight_lbs is too large")
+		}
+		for i, capacity := range req.Truck.AxleCapacities {
+			if _, ok := scale(capacity, lbsPerKgNum, lbsPerKgDen, false); !ok {
+				return fmt.Errorf("truck.axle_capacities[%d] is too large", i)
+			}
+		}
+		for i, o := range req.Orders {
+			if _, ok := scale(o.WeightLbs, lbsPerKgNum, lbsPerKgDen, true); !ok {
+				return fmt.Errorf("orders[%d].weight_lbs is too large", i)
+			}
+		}
+	}
+	if req.VolumeUnit == unitM3 {
+		if _, ok := scale(req.Truck.MaxVolumeCuft, cuftPerM3Num, cuftPerM3Den, false); !ok {
+			return fmt.Errorf("truck.max_volume_cuft is too large")
+		}
+		if _, ok := scale(req.Truck.PreloadedVolumeCuft, cuftPerM3Num, cuftPerM3Den, true); !ok {
+			return fmt.Errorf("truck.preloaded_volume_cuft is too large")
+		}
+		for i, o := range req.Orders {
+			if _, ok := scale(o.VolumeCuft, cuftPerM3Num, cuftPerM3Den, true); !ok {
+				return fmt.Errorf("orders[%d].volume_cuft is too large", i)
+			}
+		}
+	}
+	return nil
+}
+
+// toInternalUnits returns a copy of the request converted to lbs/cuft.
+// Orders are rounded up and capacities down, so any load that fits after
+// conversion also fits in the units the client sent.
+func toInternalUnits(req *OptimizeRequest) *OptimizeRequest {
+	if !isMetric(req) {
+		return req
+	}
+
+	out := *req
+	out.Orders = make([]Order, len(req.Orders))
+	copy(out.Orders, req.Orders)
+
+	if req.WeightUnit == unitKg {
+		out.Truck.MaxWeightLbs, _ = scale(req.Truck.MaxWeightLbs, lbsPerKgNum, lbsPerKgDen, false)
+		out.Truck.PreloadedWeightLbs, _ = scale(req.Truck.PreloadedWeightLbs, lbsPerKgNum, lbsPerKgDen, true)
+		out.Truck.AxleCapacities = make([]Quantity, len(req.Truck.AxleCapacities))
+		for i, capacity := range req.Truck.AxleCapacities {
+			out.Truck.AxleCapacities[i], _ = scale(capacity, lbsPerKgNum, lbsPerKgDen, false)
+		}
+		// Per-kg penalty to per-lb, rounded up so the solver never undercounts it
+		out.Truck.OveragePenaltyCentsPerLb, _ = scale(req.Truck.OveragePenaltyCentsPerLb, lbsPerKgDen, lbsPerKgNum, true)
+		for i := range out.Orders {
+			out.Orders[i].WeightLbs, _ = scale(req.Orders[i].WeightLbs, lbsPerKgNum, lbsPerKgDen, true)
+		}
+	}
+	if req.VolumeUnit == unitM3 {
+		out.Truck.MaxVolumeCuft, _ = scale(req.Truck.MaxVolumeCuft, cuftPerM3Num, cuftPerM3Den, false)
+		out.Truck.PreloadedVolumeCuft, _ = scale(req.Truck.PreloadedVolumeCuft, cuftPerM3Num, cuftPerM3Den, true)
+		for i := range out.Orders {
+			out.Orders[i].VolumeCuft, _ = scale(req.Orders[i].VolumeCuft, cuftPerM3Num, cuftPerM3Den, true)
+		}
+	}
+	return &out
+}
+
+// fromInternalUnits rewrites the response totals in the units of the original
+// request. Totals are re-summed from the client's values rather than converted
+// back, so they match exactly what the client would add up.
+func fromInternalUnits(resp *OptimizeResponse, req *OptimizeRequest) {
+	if !isMetric(req) {
+		return
+	}
+	resp.WeightUnit = unitLbs
+	if req.WeightUnit == unitKg {
+		resp.WeightUnit = unitKg
+	}
+	resp.VolumeUnit = unitCuft
+	if req.VolumeUnit == unitM3 {
+		resp.VolumeUnit = unitM3
+	}
+
+	selected := make(map[string]bool, len(resp.SelectedOrderIDs))
+	for _, id := range resp.SelectedOrderIDs {
+		selected[id] = true
+	}
+	var weight, volume Quantity
+	for _, o := range req.Orders {
+		if selected[o.ID] {
+			weight = addSaturating(weight, o.WeightLbs)
+			volume = addSaturating(volume, o.VolumeCuft)
+		}
+	}
+
+	resp.TotalWeightLbs = weight
+	resp.TotalVolumeCuft = volume
+	remainingWeight, weightPct := capacityUsage(weight, req.Truck.freeWeightLbs(), req.IgnoreWeight)
+	remainingVolume, volumePct := capacityUsage(volume, req.Truck.freeVolumeCuft(), req.IgnoreVolume)
+	resp.RemainingWeightLbs = remainingWeight
+	resp.OverageLbs = 0
+	if !req.IgnoreWeight {
+		resp.OverageLbs = max(0, weight-req.Truck.freeWeightLbs())
+	}
+	resp.OveragePenaltyCents = overageCents(resp.OverageLbs, req.Truck.OveragePenaltyCentsPerLb)
+	resp.RemainingVolumeCuft = remainingVolume
+	resp.UtilizationWeightPercent = roundPercent(weightPct)
+	resp.UtilizationVolumePercent = roundPercent(volumePct)
+}
+
+func isMetric(req *OptimizeRequest) bool {
+	return req.WeightUnit == unitKg || req.VolumeUnit == unitM3
+}
+
+// scale computes x*num/den in 128-bit integer math, rounding up or down.
+// It reports false if the result doesn't fit in an int64.
+func scale[T ~int64](x T, num, den uint64, roundUp bool) (T, bool) {
+	if x < 0 {
+		return 0, false
+	}
+	hi, lo := bits.Mul64(uint64(x), num)
+	if hi >= den {
+		return 0, false
+	}
+	q, r := bits.Div64(hi, lo, den)
+	if roundUp && r != 0 {
+		q++
+	}
+	if q > 1<<63-1 {
+		return 0, false
+	}
+	return T(q), true
+}
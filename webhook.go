@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// maxCallbackURLLength bounds ?callback_url
+const maxCallbackURLLength = 2048
+
+// webhookRetryDelay is the wait before the first retry of a failed
+// callback; it doubles with each attempt after that
+const webhookRetryDelay = time.Second
+
+// webhookClient posts job callbacks. Its timeout covers each attempt,
+// including reading the response. The callback URL comes from the client,
+// so it only connects to public addresses, checked on the address actually
+// dialed so a hostname can't resolve its way past the check, and it never
+// follows a redirect, which could point anywhere. No proxy is used, since
+// the check would then only see the proxy.
+var webhookClient = newWebhookClient()
+
+func newWebhookClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{Timeout: cfg.WebhookTimeout, Control: dialPublicOnly}).DialContext
+	return &http.Client{
+		Timeout:   cfg.WebhookTimeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return errors.New("callback redirects are not followed")
+		},
+	}
+}
+
+// webhookCtx is cancelled when the server shuts down, abandoning callbacks
+// still waiting to retry
+var webhookCtx, stopWebhooks = context.WithCancel(context.Background())
+
+// dialPublicOnly is the webhook dialer's Control hook, run for each address
+// a callback host resolves to before connecting
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddress(addrPort.Addr()) {
+		return fmt.Errorf("callback address %s is not public", addrPort.Addr())
+	}
+	return nil
+}
+
+// nonPublicPrefixes are the special-purpose ranges publicAddress rejects
+// beyond what netip classifies: "this network", carrier-grade NAT, IETF
+// protocol assignments, benchmarking, reserved, and NAT64, which can reach
+// IPv4 private addresses
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// publicAddress reports whether addr is a globally routable unicast
+// address: not loopback, private, link-local (cloud metadata services
+// live at 169.254.169.254), unspecified, multicast or otherwise reserved
+func publicAddress(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// callbackURL reads the optional ?callback_url of a job submission. It
+// returns "" when there is none, and an error when it isn't an absolute
+// http or https URL or callbacks can't be signed on this server.
+func callbackURL(r *http.Request) (string, error) {
+	raw := r.URL.Query().Get("callback_url")
+	if raw == "" {
+		return "", nil
+	}
+	if cfg.WebhookSecret == "" {
+		return "", errors.New("callback_url is disabled: WEBHOOK_SECRET is not set")
+	}
+	if len(raw) > maxCallbackURLLength {
+		return "", fmt.Errorf("callback_url must be at most %d characters", maxCallbackURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", errors.New("callback_url must be an absolute http or https URL")
+	}
+	return raw, nil
+}
+
+// signWebhook returns the hex HMAC-SHA256 of "timestamp.body" under
+// WEBHOOK_SECRET. Signing the timestamp too lets clients reject a captured
+// callback that is sent again later.
+func signWebhook(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(cfg.WebhookSecret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// deliverWebhook posts a finished job's state, the same body GET /jobs/{id}
+// returns, to its callback URL. Failed attempts, anything but a 2xx, are
+// retried up to WEBHOOK_MAX_ATTEMPTS in total with a doubling delay; after
+// that, or once the server starts shutting down, the result is only
+// available by polling.
+func deliverWebhook(callback, requestID string, resp JobResponse) {
+	body, err := json.Marshal(resp)
+	if err != nil {
+		log.Printf("request_id=%s job_id=%s callback not sent: %v", requestID, resp.JobID, err)
+		return
+	}
+
+	delay := webhookRetryDelay
+	for attempt := 1; ; attempt++ {
+		err = postWebhook(webhookCtx, callback, body)
+		if err == nil {
+			return
+		}
+		if attempt >= cfg.WebhookMaxAttempts {
+			log.Printf("request_id=%s job_id=%s callback failed after %d attempts: %v", requestID, resp.JobID, attempt, err)
+			return
+		}
+		select {
+		case <-time.After(delay):
+		case <-webhookCtx.Done():
+			log.Printf("request_id=%s job_id=%s callback abandoned at shutdown after %d attempts: %v", requestID, resp.JobID, attempt, err)
+			return
+		}
+		delay *= 2
+	}
+}
+
+// postWebhook makes one signed callback attempt
+func postWebhook(ctx context.Context, callback string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", contentTypeJSON)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(timestamp, body))
+
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("callback answered %s", res.Status)
+	}
+	return nil
+}
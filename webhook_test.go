@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestPublicAddress(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1:248:1893:25c8:1946", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"100.64.0.1", false},
+		{"::ffff:127.0.0.1", false}, // IPv4-mapped loopback
+		{"::ffff:93.184.216.34", true},
+		{"64:ff9b::a00:1", false}, // NAT64 of 10.0.0.1
+	}
+	for _, tt := range tests {
+		if got := publicAddress(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddress(%s) = %t, want %t", tt.addr, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookRefusesLoopback(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	defer srv.Close()
+
+	err := postWebhook(context.Background(), srv.URL, []byte("{}"))
+	if err == nil || !strings.Contains(err.Error(), "not public") {
+		t.Errorf("err %v, want the address refused", err)
+	}
+	if called {
+		t.Error("callback reached a loopback server")
+	}
+}
+
+func TestWebhookRefusesRedirects(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+	srv := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer srv.Close()
+
+	// Only the redirect policy is under test, so allow loopback
+	client := newWebhookClient()
+	client.Transport = http.DefaultTransport
+	res, err := client.Post(srv.URL, contentTypeJSON, strings.NewReader("{}"))
+	if err == nil {
+		res.Body.Close()
+		t.Fatalf("status %s, want the redirect refused", res.Status)
+	}
+	if !strings.Contains(err.Error(), "redirects are not followed") {
+		t.Errorf("err %v, want the redirect refused", err)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestXMLResponse(t *testing.T) {
+	defer globalCache.clear()
+	body, err := json.Marshal(testRequest(testOrder("a", 2000, 1000, 100), testOrder("b", 1000, 1000, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post := func(query string, body []byte) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/load-optimizer/optimize"+query, bytes.NewReader(body))
+		r.Header.Set("Content-Type", contentTypeJSON)
+		r.Header.Set("Accept", contentTypeXML)
+		w := httptest.NewRecorder()
+		negotiateXML(optimizeHandler)(w, r)
+		return w
+	}
+
+	w := post("", body)
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != contentTypeXML {
+		t.Fatalf("status %d, Content-Type %q: %s", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+	var resp OptimizeResponse
+	if err := xml.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal %s: %v", w.Body, err)
+	}
+	if !slices.Equal(resp.SelectedOrderIDs, []string{"a", "b"}) || resp.TotalPayoutCents != 3000 {
+		t.Errorf("selected %v for %d, want [a b] for 3000", resp.SelectedOrderIDs, resp.TotalPayoutCents)
+	}
+	if strings.Contains(w.Body.String(), "\n  <") {
+		t.Errorf("indented without ?pretty: %s", w.Body)
+	}
+
+	if w := post("?pretty=true", body); !strings.Contains(w.Body.String(), "\n  <truck_id>") {
+		t.Errorf("?pretty=true not indented: %s", w.Body)
+	}
+
+	// Index 0 is a zero value, which omitempty would drop from the list
+	w = post("?include_indices=true", body)
+	resp = OptimizeResponse{}
+	if err := xml.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal %s: %v", w.Body, err)
+	}
+	if !slices.Equal(resp.SelectedOrderIndices, []int{0, 1}) {
+		t.Errorf("selected_order_indices %v, want [0 1]: %s", resp.SelectedOrderIndices, w.Body)
+	}
+
+	w = post("", []byte(`{"truck":`))
+	var errResp ErrorResponse
+	if err := xml.Unmarshal(w.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("unmarshal error %s: %v", w.Body, err)
+	}
+	if w.Code != http.StatusBadRequest || errResp.Code == "" {
+		t.Errorf("status %d, code %q, want %d with a code", w.Code, errResp.Code, http.StatusBadRequest)
+	}
+}
+
+func TestXMLBestAdditionAxles(t *testing.T) {
+	// An axle group with no room to make is a zero that must stay in the list
+	data, err := xml.Marshal(BestAddition{OrderID: "b", OrderIDs: []string{"b"}, MakeRoomAxleLbs: []Quantity{wholeQuantity(5000), 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<make_room_axle_lbs><axle>5000</axle><axle>0</axle></make_room_axle_lbs>"; !strings.Contains(string(data), want) {
+		t.Errorf("marshaled %s, want %s", data, want)
+	}
+}